@@ -0,0 +1,157 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"sort"
+)
+
+// APISignature returns the sorted signatures of the exported API of the package,
+// such as the consts and vars with their types, the funcs with their parameter and
+// result types, and the types with their exported fields and methods.
+// The parameter names are omitted, so that comparing the results of two versions
+// reports the incompatible changes only.
+func (p *PackageInfo) APISignature() []string {
+	qf := types.RelativeTo(p.Pkg)
+	scope := p.Pkg.Scope()
+	var list []string
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Const:
+			list = append(list, "const "+name+" "+types.TypeString(obj.Type(), qf))
+		case *types.Var:
+			list = append(list, "var "+name+" "+types.TypeString(obj.Type(), qf))
+		case *types.Func:
+			list = append(list, "func "+name+signatureString(obj.Type().(*types.Signature), qf))
+		case *types.TypeName:
+			list = append(list, typeAPISignature(obj, qf)...)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func typeAPISignature(obj *types.TypeName, qf types.Qualifier) []string {
+	name := obj.Name()
+	if obj.IsAlias() {
+		return []string{"type " + name + " = " + types.TypeString(types.Unalias(obj.Type()), qf)}
+	}
+	var list []string
+	typ := obj.Type()
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		list = append(list, "type "+name+" struct")
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Exported() {
+				list = append(list, "field "+name+"."+f.Name()+" "+types.TypeString(f.Type(), qf))
+			}
+		}
+	case *types.Interface:
+		list = append(list, "type "+name+" interface")
+		for i := 0; i < u.NumMethods(); i++ {
+			m := u.Method(i)
+			if m.Exported() {
+				list = append(list, "method ("+name+") "+m.Name()+signatureString(m.Type().(*types.Signature), qf))
+			} else {
+				list = append(list, "method ("+name+") unexported")
+			}
+		}
+		return list
+	default:
+		list = append(list, "type "+name+" "+types.TypeString(u, qf))
+	}
+	// the method set of *T includes the method set of T and the promoted methods
+	mset, valueMset := types.NewMethodSet(types.NewPointer(typ)), types.NewMethodSet(typ)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj().(*types.Func)
+		if !m.Exported() {
+			continue
+		}
+		recv := name
+		if valueMset.Lookup(m.Pkg(), m.Name()) == nil {
+			recv = "*" + name
+		}
+		list = append(list, "method ("+recv+") "+m.Name()+signatureString(m.Type().(*types.Signature), qf))
+	}
+	return list
+}
+
+// signatureString returns the signature without the func keyword and parameter names,
+// such as `(int, ...string) (bool, error)`.
+func signatureString(sig *types.Signature, qf types.Qualifier) string {
+	return types.TypeString(unnamedSignature(sig), qf)[len("func"):]
+}
+
+// MethodJSON is the JSON form of a method in the result of Facade.MethodsJSON.
+type MethodJSON struct {
+	Name      string `json:"name"`
+	Receiver  string `json:"receiver"`  // T or *T; the interface name for an interface
+	Signature string `json:"signature"` // such as func(p []byte) (n int, err error)
+	Doc       string `json:"doc,omitempty"`
+	Exported  bool   `json:"exported"`
+	Promoted  bool   `json:"promoted,omitempty"` // promoted from an embedded field
+}
+
+// MethodsJSON returns the method set of *T for the named type T as indented JSON,
+// including the promoted methods, in the order of types.MethodSet, such as:
+//  {"type": "T", "package": "path/to/pkg", "methods": [{"name": "M", ...}]}
+// For an interface, it returns the interface methods.
+// NOTE: Return error, if ObjKind != Typ
+func (fa *facade) MethodsJSON() ([]byte, error) {
+	if fa.ObjKind() != Typ {
+		return nil, fmt.Errorf("aster: %s is not a type", fa.Name())
+	}
+	qf := types.RelativeTo(fa.pkg.Pkg)
+	name := fa.Name()
+	typ := fa.obj.Type()
+	var mset, valueMset *types.MethodSet
+	if types.IsInterface(typ) {
+		mset = types.NewMethodSet(typ)
+		valueMset = mset
+	} else {
+		mset, valueMset = types.NewMethodSet(types.NewPointer(typ)), types.NewMethodSet(typ)
+	}
+	methods := make([]MethodJSON, 0, mset.Len())
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+		m := sel.Obj().(*types.Func)
+		method := MethodJSON{
+			Name:      m.Name(),
+			Receiver:  name,
+			Signature: types.TypeString(m.Type(), qf),
+			Exported:  m.Exported(),
+			Promoted:  len(sel.Index()) > 1,
+		}
+		if valueMset.Lookup(m.Pkg(), m.Name()) == nil {
+			method.Receiver = "*" + name
+		}
+		if mfa, ok := fa.pkg.prog.facadeOf(m); ok {
+			method.Doc = mfa.Doc()
+		}
+		methods = append(methods, method)
+	}
+	return json.MarshalIndent(struct {
+		Type    string       `json:"type"`
+		Package string       `json:"package"`
+		Methods []MethodJSON `json:"methods"`
+	}{name, fa.pkg.Pkg.Path(), methods}, "", "\t")
+}
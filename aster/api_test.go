@@ -0,0 +1,118 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestAPISignature(t *testing.T) {
+	var src = `package test
+import "io"
+const Max = 10
+const Version string = "v1"
+var Default *Client
+type Client struct {
+	Name string
+	io.Reader
+	conn int
+}
+func (c Client) String() string { return "" }
+func (c *Client) Do(path string, args ...interface{}) (n int, err error) { return }
+func (c *Client) close() {}
+type Getter interface {
+	Get(key string) (string, bool)
+}
+type ID int64
+type Alias = Client
+func New(name string, opts map[string]ID) (*Client, error) { return nil, nil }
+func helper() {}
+`
+	prog, err := aster.LoadFile("../_out/api_signature.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `const Max untyped int
+const Version string
+field Client.Name string
+field Client.Reader io.Reader
+func New(string, map[string]ID) (*Client, error)
+method (*Client) Do(string, ...interface{}) (int, error)
+method (Client) Read([]byte) (int, error)
+method (Client) String() string
+method (Getter) Get(string) (string, bool)
+type Alias = Client
+type Client struct
+type Getter interface
+type ID int64
+var Default *Client`
+	pkg := prog.Package("test")
+	for i := 0; i < 2; i++ {
+		if got := strings.Join(pkg.APISignature(), "\n"); got != want {
+			t.Fatalf("APISignature: want:\n%s\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestMethodsJSON(t *testing.T) {
+	var src = `package test
+type Counter struct{ n int }
+// Get returns the count.
+func (c Counter) Get() int { return c.n }
+// Add adds delta to the count.
+func (c *Counter) Add(delta int) {}
+func (c *Counter) reset() {}
+`
+	prog, err := aster.LoadFile("../_out/methods_json.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := prog.Lookup(aster.Typ, aster.Struct, "Counter")[0].MethodsJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `{
+	"type": "Counter",
+	"package": "test",
+	"methods": [
+		{
+			"name": "Add",
+			"receiver": "*Counter",
+			"signature": "func(delta int)",
+			"doc": "Add adds delta to the count.\n",
+			"exported": true
+		},
+		{
+			"name": "Get",
+			"receiver": "Counter",
+			"signature": "func() int",
+			"doc": "Get returns the count.\n",
+			"exported": true
+		},
+		{
+			"name": "reset",
+			"receiver": "*Counter",
+			"signature": "func()",
+			"exported": false
+		}
+	]
+}`
+	if string(b) != want {
+		t.Fatalf("MethodsJSON: want:\n%s\ngot:\n%s", want, b)
+	}
+}
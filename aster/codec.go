@@ -0,0 +1,325 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CodecGenerator generates the encoding methods of a defined struct type.
+type CodecGenerator func(Facade) (string, error)
+
+var codecs = map[string]CodecGenerator{
+	"json": generateJSONCodec,
+}
+
+// RegisterCodec registers the code generator of the encoding format,
+// which is used by Facade.GenerateCodec.
+// NOTE:
+//  Panic, if gen is nil or the name has been registered;
+//  It is not safe for concurrent use, so call it in the init functions.
+func RegisterCodec(name string, gen CodecGenerator) {
+	if gen == nil {
+		panic("aster: RegisterCodec generator is nil")
+	}
+	if _, dup := codecs[name]; dup {
+		panic("aster: RegisterCodec called twice for codec " + name)
+	}
+	codecs[name] = gen
+}
+
+// GenerateCodec generates the encoding methods of the named struct type
+// by the codec registered for the format.
+// The builtin json codec generates the MarshalJSON and UnmarshalJSON methods like encoding/json,
+// which require importing bytes, encoding/json and strings, and returns error
+// for the embedded struct fields without json names or the string option.
+// NOTE: Return error, if TypKind != Struct, it is not a defined type or the format is unknown.
+func (fa *facade) GenerateCodec(format string) (string, error) {
+	if _, err := fa.namedStruct(); err != nil {
+		return "", err
+	}
+	gen, ok := codecs[format]
+	if !ok {
+		return "", fmt.Errorf("aster: unknown codec %q", format)
+	}
+	return gen(fa)
+}
+
+// JSONExample returns an indented example JSON object of the struct for documentation,
+// keyed by the json tag names, or the field names, in the order of the fields.
+// The values are the placeholders of the field types: false, 0, "", [] for slices and arrays,
+// {} for maps, null for interfaces, and the example objects of the nested structs,
+// whose embedded fields without json names are promoted like encoding/json.
+// The omitempty fields are included, and the fields of the string option are quoted.
+// NOTE: Return error, if TypKind != Struct
+func (fa *facade) JSONExample() ([]byte, error) {
+	if fa.TypKind() != Struct {
+		return nil, fmt.Errorf("aster: JSONExample of non-Struct TypKind: %s", fa.TypKind())
+	}
+	var buf bytes.Buffer
+	writeJSONExample(&buf, fa.structure(), make(map[types.Type]bool))
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// jsonExampleField is a field of the JSON example object.
+type jsonExampleField struct {
+	key   string
+	typ   types.Type
+	quote bool // by the string option
+	depth int
+}
+
+// writeJSONExample writes the example value of typ,
+// where seen contains the struct types being written to stop the recursion.
+func writeJSONExample(buf *bytes.Buffer, typ types.Type, seen map[types.Type]bool) {
+	if isNamed(typ, "time", "Time") {
+		buf.WriteString(`"0001-01-01T00:00:00Z"`)
+		return
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch info := t.Info(); {
+		case info&types.IsBoolean != 0:
+			buf.WriteString("false")
+		case info&types.IsNumeric != 0:
+			buf.WriteString("0")
+		case info&types.IsString != 0:
+			buf.WriteString(`""`)
+		default:
+			buf.WriteString("null")
+		}
+	case *types.Pointer:
+		writeJSONExample(buf, t.Elem(), seen)
+	case *types.Slice:
+		if b, ok := t.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte {
+			buf.WriteString(`""`) // base64
+		} else {
+			buf.WriteString("[]")
+		}
+	case *types.Array:
+		buf.WriteString("[]")
+	case *types.Map:
+		buf.WriteString("{}")
+	case *types.Struct:
+		if seen[t] {
+			buf.WriteString("null")
+			return
+		}
+		seen[t] = true
+		buf.WriteByte('{')
+		for i, f := range jsonExampleFields(t, 0, make(map[types.Type]bool)) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(strconv.Quote(f.key) + ":")
+			if f.quote {
+				var v bytes.Buffer
+				writeJSONExample(&v, f.typ, seen)
+				buf.WriteString(strconv.Quote(v.String()))
+			} else {
+				writeJSONExample(buf, f.typ, seen)
+			}
+		}
+		buf.WriteByte('}')
+		delete(seen, t)
+	default:
+		buf.WriteString("null")
+	}
+}
+
+// jsonExampleFields returns the encoded fields of the struct at the depth of embedding,
+// in which a shallower field hides the deeper ones of the same key.
+func jsonExampleFields(s *types.Struct, depth int, embedding map[types.Type]bool) []jsonExampleField {
+	var list []jsonExampleField
+	for i := 0; i < s.NumFields(); i++ {
+		v := s.Field(i)
+		tag := reflect.StructTag(s.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		typ := v.Type()
+		if ptr, ok := typ.(*types.Pointer); ok && v.Embedded() {
+			typ = ptr.Elem()
+		}
+		if v.Embedded() && name == "" {
+			if st, ok := typ.Underlying().(*types.Struct); ok {
+				if !embedding[typ] {
+					embedding[typ] = true
+					list = append(list, jsonExampleFields(st, depth+1, embedding)...)
+					delete(embedding, typ)
+				}
+				continue
+			}
+		}
+		if !v.Exported() {
+			continue
+		}
+		switch v.Type().Underlying().(type) {
+		case *types.Chan, *types.Signature:
+			continue
+		}
+		if name == "" {
+			name = v.Name()
+		}
+		var quote bool
+		for _, opt := range strings.Split(opts, ",") {
+			quote = quote || opt == "string"
+		}
+		list = append(list, jsonExampleField{key: name, typ: v.Type(), quote: quote, depth: depth})
+	}
+	if depth > 0 {
+		return list
+	}
+	shallowest := make(map[string]int)
+	for _, f := range list {
+		if d, ok := shallowest[f.key]; !ok || f.depth < d {
+			shallowest[f.key] = f.depth
+		}
+	}
+	fields := list[:0]
+	for _, f := range list {
+		if d, ok := shallowest[f.key]; ok && d == f.depth {
+			fields = append(fields, f)
+			delete(shallowest, f.key)
+		}
+	}
+	return fields
+}
+
+// generateJSONCodec generates the MarshalJSON and UnmarshalJSON methods,
+// which encode the exported fields by the json tags like encoding/json without reflection on the struct.
+// The omitempty option is supported, and the keys are matched case-insensitively when decoding,
+// preferring an exact match.
+// NOTE:
+//  Return error, if an embedded struct field without json name, whose fields are promoted, or the string option is used;
+//  The generated code requires importing bytes, encoding/json and strings.
+func generateJSONCodec(f Facade) (string, error) {
+	fa := f.(*facade)
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name, "b", "m", "v", "t")
+
+	var fields []*StructField
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		if field.WireName("json") == "" {
+			continue
+		}
+		tag, _ := field.Tags().Get("json")
+		if field.Embedded() && (tag == nil || tag.Name == "") {
+			typ := field.obj.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			if _, ok := typ.Underlying().(*types.Struct); ok {
+				return "", fmt.Errorf("aster: GenerateCodec(json) of %s with the promoted fields of the embedded %s", name, field.Name())
+			}
+		}
+		if !field.Exported() {
+			continue
+		}
+		if tag != nil && tag.HasOption("string") {
+			return "", fmt.Errorf("aster: GenerateCodec(json) of %s with the string option of the field %s", name, field.Name())
+		}
+		fields = append(fields, field)
+	}
+
+	fmt.Fprintf(&buf, "// MarshalJSON encodes %s as a JSON object.\n", name)
+	fmt.Fprintf(&buf, "func (%s %s) MarshalJSON() ([]byte, error) {\n", r, name)
+	buf.WriteString("buf := []byte{'{'}\n")
+	if len(fields) > 0 {
+		buf.WriteString("var b []byte\nvar err error\n")
+	}
+	for _, field := range fields {
+		x := r + "." + field.Name()
+		omit := ""
+		if tag, err := field.Tags().Get("json"); err == nil && tag.HasOption("omitempty") {
+			omit = jsonEmptyCheck(x, field.obj.Type())
+		}
+		if omit != "" {
+			fmt.Fprintf(&buf, "if !(%s) {\n", omit)
+		}
+		fmt.Fprintf(&buf, "if b, err = json.Marshal(%s); err != nil {\nreturn nil, err\n}\n", x)
+		buf.WriteString("if len(buf) > 1 {\nbuf = append(buf, ',')\n}\n")
+		fmt.Fprintf(&buf, "buf = append(buf, %q...)\nbuf = append(buf, b...)\n", jsonKey(field.WireName("json"))+":")
+		if omit != "" {
+			buf.WriteString("}\n")
+		}
+	}
+	buf.WriteString("return append(buf, '}'), nil\n}\n\n")
+
+	fmt.Fprintf(&buf, "// UnmarshalJSON decodes %s from a JSON object.\n", name)
+	fmt.Fprintf(&buf, "func (%s *%s) UnmarshalJSON(data []byte) error {\n", r, name)
+	if len(fields) == 0 {
+		buf.WriteString("var m map[string]json.RawMessage\nreturn json.Unmarshal(data, &m)\n}\n")
+		return formatCode(buf.Bytes())
+	}
+	buf.WriteString("dec := json.NewDecoder(bytes.NewReader(data))\n")
+	buf.WriteString("if t, err := dec.Token(); err != nil || t != json.Delim('{') {\n")
+	buf.WriteString("// null is a no-op, and the other values are rejected\n")
+	buf.WriteString("var m map[string]json.RawMessage\nreturn json.Unmarshal(data, &m)\n}\n")
+	buf.WriteString("for dec.More() {\nt, err := dec.Token()\nif err != nil {\nreturn err\n}\nkey, _ := t.(string)\n")
+	buf.WriteString("var v json.RawMessage\nif err = dec.Decode(&v); err != nil {\nreturn err\n}\nswitch {\n")
+	for _, field := range fields {
+		fmt.Fprintf(&buf, "case key == %q:\nerr = json.Unmarshal(v, &%s.%s)\n", field.WireName("json"), r, field.Name())
+	}
+	for _, field := range fields {
+		fmt.Fprintf(&buf, "case strings.EqualFold(key, %q):\nerr = json.Unmarshal(v, &%s.%s)\n", field.WireName("json"), r, field.Name())
+	}
+	buf.WriteString("}\nif err != nil {\nreturn err\n}\n}\nreturn nil\n}\n")
+	return formatCode(buf.Bytes())
+}
+
+// jsonKey returns the key encoded by encoding/json, which escapes HTML characters.
+func jsonKey(key string) string {
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+// jsonEmptyCheck returns the expression reporting whether x is empty for the omitempty option,
+// or "" if x is never omitted, such as a struct.
+func jsonEmptyCheck(x string, typ types.Type) string {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return "!" + x
+		case t.Info()&types.IsNumeric != 0:
+			return x + " == 0"
+		case t.Info()&types.IsString != 0:
+			return x + ` == ""`
+		}
+	case *types.Pointer, *types.Interface:
+		return x + " == nil"
+	case *types.Slice, *types.Map, *types.Array:
+		return "len(" + x + ") == 0"
+	}
+	return ""
+}
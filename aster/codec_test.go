@@ -0,0 +1,268 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func init() {
+	aster.RegisterCodec("names", func(fa aster.Facade) (string, error) {
+		var names []string
+		for i := 0; i < fa.NumFields(); i++ {
+			names = append(names, fa.Field(i).Name())
+		}
+		return fmt.Sprintf("func (%s) FieldNames() []string {\nreturn %#v\n}\n", fa.Name(), names), nil
+	})
+}
+
+func TestGenerateCodec(t *testing.T) {
+	var src = `package test
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+var _ json.Marshaler
+var _ = bytes.NewReader
+var _ = strings.EqualFold
+type Inner struct {
+	X int
+}
+type S struct {
+	A string ` + "`json:\"a\"`" + `
+	B int    ` + "`json:\",omitempty\"`" + `
+	C *Inner ` + "`json:\"c,omitempty\"`" + `
+	D bool   ` + "`json:\"-\"`" + `
+	e int
+	N
+}
+type N int
+type Embedded struct {
+	Inner
+}
+type Str struct {
+	N int ` + "`json:\",string\"`" + `
+}
+`
+	prog, err := aster.LoadFile("../_out/codec.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "S")[0]
+	code, err := s.GenerateCodec("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		`func (s S) MarshalJSON() ([]byte, error) {`,
+		`func (s *S) UnmarshalJSON(data []byte) error {`,
+		`buf = append(buf, "\"a\":"...)`,
+		`if !(s.B == 0) {`,
+		`if !(s.C == nil) {`,
+		`case key == "N":`,
+		`case strings.EqualFold(key, "a"):`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateCodec(json): want %q in code", want)
+		}
+	}
+	for _, skipped := range []string{`"D"`, `s.e`} {
+		if strings.Contains(code, skipped) {
+			t.Fatalf("GenerateCodec(json): want %s skipped", skipped)
+		}
+	}
+	mustCompile(t, src, code)
+
+	code, err = s.GenerateCodec("names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(code, `[]string{"A", "B", "C", "D", "e", "N"}`) {
+		t.Fatalf("GenerateCodec(names): got %s", code)
+	}
+	mustCompile(t, src, code)
+
+	if _, err = s.GenerateCodec("msgpack"); err == nil {
+		t.Fatal("GenerateCodec(msgpack): want error for an unknown codec")
+	}
+	for _, name := range []string{"Embedded", "Str"} {
+		if _, err = prog.Lookup(aster.Typ, aster.Struct, name)[0].GenerateCodec("json"); err == nil {
+			t.Fatalf("GenerateCodec(json): want error for %s", name)
+		}
+	}
+}
+
+func TestJSONExample(t *testing.T) {
+	var src = `package test
+import "time"
+type Base struct {
+	ID      int64     ` + "`json:\"id\"`" + `
+	Created time.Time ` + "`json:\"created\"`" + `
+}
+type Address struct {
+	City string ` + "`json:\"city,omitempty\"`" + `
+	Zip  int    ` + "`json:\"zip,string\"`" + `
+}
+type User struct {
+	Base
+	Name    string            ` + "`json:\"name\"`" + `
+	Admin   bool
+	Tags    []string          ` + "`json:\"tags,omitempty\"`" + `
+	Meta    map[string]string ` + "`json:\"meta\"`" + `
+	Home    *Address          ` + "`json:\"home\"`" + `
+	Extra   interface{}       ` + "`json:\"extra\"`" + `
+	Parent  *User             ` + "`json:\"parent\"`" + `
+	secret  string
+	Ignored string            ` + "`json:\"-\"`" + `
+}
+var N = 1
+`
+	prog, err := aster.LoadFile("../_out/json_example.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := prog.Lookup(aster.Typ, aster.Struct, "User")[0]
+	b, err := user.JSONExample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `{
+  "id": 0,
+  "created": "0001-01-01T00:00:00Z",
+  "name": "",
+  "Admin": false,
+  "tags": [],
+  "meta": {},
+  "home": {
+    "city": "",
+    "zip": "0"
+  },
+  "extra": null,
+  "parent": null
+}`
+	if string(b) != want {
+		t.Fatalf("JSONExample: want:\n%s\ngot:\n%s", want, b)
+	}
+	if _, err = prog.Lookup(aster.Var, 0, "N")[0].JSONExample(); err == nil {
+		t.Fatal("JSONExample(N): want error")
+	}
+}
+
+func TestGenerateCodecRoundTrip(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip(err)
+	}
+	var src = `package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type ID int
+
+type Inner struct {
+	X int
+}
+
+type S struct {
+	A    string            ` + "`json:\"a\"`" + `
+	B    int               ` + "`json:\",omitempty\"`" + `
+	C    *Inner            ` + "`json:\"c,omitempty\"`" + `
+	D    bool              ` + "`json:\"-\"`" + `
+	E    []byte            ` + "`json:\"a<b\"`" + `
+	M    map[string]int    ` + "`json:\"m,omitempty\"`" + `
+	Name string
+	e    int
+	ID
+}
+
+// plain has the fields of S without the generated methods.
+type plain S
+`
+	prog, err := aster.LoadFile("../_out/codec_round_trip.go", src+"func main() {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := prog.Lookup(aster.Typ, aster.Struct, "S")[0].GenerateCodec("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var main = `
+var values = []S{
+	{},
+	{A: "x", B: 1, C: &Inner{X: 2}, D: true, E: []byte("<>"), M: map[string]int{"k": 1}, Name: "n", e: 3, ID: 4},
+}
+
+var inputs = []string{
+	` + "`null`" + `,
+	` + "`{}`" + `,
+	` + "`{\"a\":\"x\",\"B\":2,\"c\":{\"X\":1},\"D\":true,\"unknown\":1}`" + `,
+	` + "`{\"A\":\"x\",\"b\":3,\"NAME\":\"n\",\"id\":5,\"A<B\":\"AQ==\"}`" + `,
+	` + "`{\"a\":\"x\",\"A\":\"y\",\"name\":\"m\",\"Name\":\"n\"}`" + `,
+	` + "`{\"m\":{\"k\":1},\"c\":null}`" + `,
+}
+
+func main() {
+	var failed bool
+	for _, v := range values {
+		got, err1 := json.Marshal(v)
+		want, err2 := json.Marshal(plain(v))
+		if !bytes.Equal(got, want) || (err1 == nil) != (err2 == nil) {
+			fmt.Printf("Marshal(%+v): want %s %v, got %s %v\n", v, want, err2, got, err1)
+			failed = true
+		}
+	}
+	for _, in := range inputs {
+		var got S
+		var want plain
+		err1 := json.Unmarshal([]byte(in), &got)
+		err2 := json.Unmarshal([]byte(in), &want)
+		if !reflect.DeepEqual(plain(got), want) || (err1 == nil) != (err2 == nil) {
+			fmt.Printf("Unmarshal(%s): want %+v %v, got %+v %v\n", in, want, err2, got, err1)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+var _ = strings.EqualFold
+`
+	file := filepath.Join(t.TempDir(), "main.go")
+	if err = os.WriteFile(file, []byte(src+code+main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "run", file)
+	cmd.Env = append(os.Environ(), "GOFLAGS=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("GenerateCodec(json): differs from encoding/json: %v\n%s\n%s", err, out, code)
+	}
+}
@@ -0,0 +1,438 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnumGroup is a const block whose members all share
+// a named integer or string type of the package.
+type EnumGroup struct {
+	Type    Facade // the named type of the members
+	Decl    *ast.GenDecl
+	Members []*EnumMember // in declaration order
+}
+
+// EnumMember is a constant of an enum group.
+type EnumMember struct {
+	Name    string
+	Value   constant.Value
+	Doc     string // lead comment
+	Comment string // line comment
+}
+
+// EnumGroups returns the enum groups declared in the package.
+func (p *PackageInfo) EnumGroups() []*EnumGroup {
+	var groups []*EnumGroup
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			if g := p.enumGroup(decl); g != nil {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+func (p *PackageInfo) enumGroup(decl ast.Decl) *EnumGroup {
+	gen, ok := decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.CONST {
+		return nil
+	}
+	var named *types.Named
+	var members []*EnumMember
+	for _, spec := range gen.Specs {
+		vs := spec.(*ast.ValueSpec)
+		for _, ident := range vs.Names {
+			obj, ok := p.info.Defs[ident].(*types.Const)
+			if !ok {
+				return nil
+			}
+			t, ok := obj.Type().(*types.Named)
+			if !ok || (named != nil && t != named) {
+				return nil
+			}
+			named = t
+			members = append(members, &EnumMember{
+				Name:    ident.Name,
+				Value:   obj.Val(),
+				Doc:     vs.Doc.Text(),
+				Comment: vs.Comment.Text(),
+			})
+		}
+	}
+	if named == nil {
+		return nil
+	}
+	basic, ok := named.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+		return nil
+	}
+	typ, idx := p.getFacadeByObj(named.Obj())
+	if idx < 0 {
+		return nil
+	}
+	return &EnumGroup{
+		Type:    typ,
+		Decl:    gen,
+		Members: members,
+	}
+}
+
+// enumGroups returns the enum groups of the named type in its package.
+func (fa *facade) enumGroups() []*EnumGroup {
+	var groups []*EnumGroup
+	for _, g := range fa.pkg.EnumGroups() {
+		if g.Type == Facade(fa) {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// uniqueEnumMembers returns the first member of each value in the groups.
+func uniqueEnumMembers(groups []*EnumGroup) []*EnumMember {
+	var members []*EnumMember
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		for _, m := range g.Members {
+			if !seen[m.Value.ExactString()] {
+				seen[m.Value.ExactString()] = true
+				members = append(members, m)
+			}
+		}
+	}
+	return members
+}
+
+// IsBitFlag reports whether the named integer type is a bit flag enum,
+// whose constants are declared with the `<< iota` value expressions, such as `A Flag = 1 << iota`.
+func (fa *facade) IsBitFlag() bool {
+	if fa.ObjKind() != Typ || fa.IsAlias() {
+		return false
+	}
+	iota := types.Universe.Lookup("iota")
+	for _, g := range fa.enumGroups() {
+		for _, spec := range g.Decl.Specs {
+			for _, value := range spec.(*ast.ValueSpec).Values {
+				var found bool
+				ast.Inspect(value, func(n ast.Node) bool {
+					if b, ok := n.(*ast.BinaryExpr); ok && b.Op == token.SHL {
+						if id, ok := ast.Unparen(b.Y).(*ast.Ident); ok && fa.pkg.info.Uses[id] == iota {
+							found = true
+						}
+					}
+					return !found
+				})
+				if found {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// GenerateStringer generates the String method of the named integer enum type,
+// which returns the constant names, or `T(N)` for an unknown value.
+// For a bit flag enum, it returns the names of the set flags joined by "|",
+// such as "Read|Write", followed by `T(0xN)` for the unknown bits.
+// If zeroAlloc is true, String returns the known names of a non-bit-flag enum without allocation:
+// a contiguous enum slices the _T_name string by the _T_index table, like the stringer tool,
+// and a non-contiguous enum looks up the _T_map map.
+// NOTE:
+//  Return error, if it is not a defined integer type with constants in enum groups;
+//  The generated code requires importing strconv.
+func (fa *facade) GenerateStringer(zeroAlloc bool) (string, error) {
+	groups := fa.enumGroups()
+	if len(groups) == 0 {
+		return "", fmt.Errorf("aster: %s is not an enum type", fa.Name())
+	}
+	basic := fa.typ().(*types.Basic)
+	if basic.Info()&types.IsInteger == 0 {
+		return "", fmt.Errorf("aster: %s is not an integer type", fa.Name())
+	}
+	members := uniqueEnumMembers(groups)
+	format := "strconv.FormatInt(int64(%s), %d)"
+	if basic.Info()&types.IsUnsigned != 0 {
+		format = "strconv.FormatUint(uint64(%s), %d)"
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	zeroAlloc = zeroAlloc && !fa.IsBitFlag()
+	reserved := []string{"s"}
+	if zeroAlloc {
+		reserved = append(reserved, "i")
+	}
+	r := receiverName(name, reserved...)
+	fmt.Fprintf(&buf, "// String returns the name of the %s value.\n", name)
+	fmt.Fprintf(&buf, "func (%s %s) String() string {\n", r, name)
+	unknown := fmt.Sprintf("return \"%s(\" + %s + \")\"\n}\n", name, fmt.Sprintf(format, r, 10))
+	if zeroAlloc {
+		writeZeroAllocStringer(&buf, name, r, members, basic.Info()&types.IsUnsigned != 0, unknown)
+		return formatCode(buf.Bytes())
+	}
+	if !fa.IsBitFlag() {
+		fmt.Fprintf(&buf, "switch %s {\n", r)
+		for _, m := range members {
+			fmt.Fprintf(&buf, "case %s:\nreturn %q\n", m.Name, m.Name)
+		}
+		buf.WriteString("}\n" + unknown)
+		return formatCode(buf.Bytes())
+	}
+	var flags []*EnumMember
+	for _, m := range members {
+		if constant.Sign(m.Value) == 0 {
+			fmt.Fprintf(&buf, "if %s == 0 {\nreturn %q\n}\n", r, m.Name)
+		} else {
+			flags = append(flags, m)
+		}
+	}
+	fmt.Fprintf(&buf, "var s string\nrest := %s\n", r)
+	if len(flags) > 0 {
+		fmt.Fprintf(&buf, "for _, flag := range [...]struct {\nvalue %s\nname string\n}{\n", name)
+		for _, m := range flags {
+			fmt.Fprintf(&buf, "{%s, %q},\n", m.Name, m.Name)
+		}
+		fmt.Fprintf(&buf, "} {\nif %s&flag.value == flag.value {\n", r)
+		buf.WriteString("if s != \"\" {\ns += \"|\"\n}\ns += flag.name\nrest &^= flag.value\n}\n}\n")
+	}
+	buf.WriteString("if rest != 0 || s == \"\" {\nif s != \"\" {\ns += \"|\"\n}\n")
+	fmt.Fprintf(&buf, "s += \"%s(0x\" + %s + \")\"\n}\nreturn s\n}\n", name, fmt.Sprintf(format, "rest", 16))
+	return formatCode(buf.Bytes())
+}
+
+// writeZeroAllocStringer writes the body of the String method with the name tables,
+// which are declared after the method, and then the tables.
+func writeZeroAllocStringer(buf *bytes.Buffer, name, r string, members []*EnumMember, unsigned bool, unknown string) {
+	sorted := append([]*EnumMember(nil), members...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return constant.Compare(sorted[i].Value, token.LSS, sorted[j].Value)
+	})
+	one := constant.MakeInt64(1)
+	contiguous := true
+	for i := 1; i < len(sorted); i++ {
+		next := constant.BinaryOp(sorted[i-1].Value, token.ADD, one)
+		if !constant.Compare(sorted[i].Value, token.EQL, next) {
+			contiguous = false
+			break
+		}
+	}
+	if !contiguous {
+		fmt.Fprintf(buf, "if s, ok := _%s_map[%s]; ok {\nreturn s\n}\n%s\n", name, r, unknown)
+		fmt.Fprintf(buf, "var _%s_map = map[%s]string{\n", name, name)
+		for _, m := range members {
+			fmt.Fprintf(buf, "%s: %q,\n", m.Name, m.Name)
+		}
+		buf.WriteString("}\n")
+		return
+	}
+	var names strings.Builder
+	index := []string{"0"}
+	for _, m := range sorted {
+		names.WriteString(m.Name)
+		index = append(index, strconv.Itoa(names.Len()))
+	}
+	indexType := "uint8"
+	if names.Len() > math.MaxUint8 {
+		indexType = "uint16"
+	}
+	i := r
+	switch min := sorted[0].Value; constant.Sign(min) {
+	case 1:
+		i = "i"
+		fmt.Fprintf(buf, "i := %s - %s\n", r, min.ExactString())
+	case -1:
+		i = "i"
+		fmt.Fprintf(buf, "i := %s + %s\n", r, constant.UnaryOp(token.SUB, min, 0).ExactString())
+	}
+	if unsigned {
+		fmt.Fprintf(buf, "if %s >= %s(len(_%s_index)-1) {\n", i, name, name)
+	} else {
+		fmt.Fprintf(buf, "if %s < 0 || %s >= %s(len(_%s_index)-1) {\n", i, i, name, name)
+	}
+	buf.WriteString(unknown)
+	fmt.Fprintf(buf, "return _%s_name[_%s_index[%s]:_%s_index[%s+1]]\n}\n\n", name, name, i, name, i)
+	fmt.Fprintf(buf, "const _%s_name = %q\n\n", name, names.String())
+	fmt.Fprintf(buf, "var _%s_index = [...]%s{%s}\n", name, indexType, strings.Join(index, ", "))
+}
+
+// GenerateSQLScanValuer generates the Value method of driver.Valuer and the Scan method of sql.Scanner
+// for the named basic type, which store it as the driver value of its kind:
+// string, int64, float64 or bool. Scan also accepts []byte for a string type.
+// For an enum type, Scan rejects the values other than the constants,
+// or for a bit flag enum type, the values with bits other than the ones of the constants.
+// NOTE:
+//  Return error, if it is not a defined string, numeric or boolean type;
+//  The generated code requires importing database/sql/driver and fmt.
+func (fa *facade) GenerateSQLScanValuer() (string, error) {
+	if fa.ObjKind() != Typ || fa.IsAlias() || fa.typKind() != named {
+		return "", fmt.Errorf("aster: %s is not a defined type", fa.Name())
+	}
+	basic, ok := fa.typ().(*types.Basic)
+	if !ok {
+		return "", fmt.Errorf("aster: %s is not a basic type", fa.Name())
+	}
+	var driverType string
+	var scanTypes []string
+	switch info := basic.Info(); {
+	case info&types.IsString != 0:
+		driverType, scanTypes = "string", []string{"string", "[]byte"}
+	case info&types.IsInteger != 0:
+		driverType, scanTypes = "int64", []string{"int64"}
+	case info&types.IsFloat != 0:
+		driverType, scanTypes = "float64", []string{"float64", "int64"}
+	case info&types.IsBoolean != 0:
+		driverType, scanTypes = "bool", []string{"bool"}
+	default:
+		return "", fmt.Errorf("aster: %s can not be a driver value", fa.Name())
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name, "v", "x")
+	fmt.Fprintf(&buf, "// Value implements the driver.Valuer interface.\n")
+	fmt.Fprintf(&buf, "func (%s %s) Value() (driver.Value, error) {\nreturn %s(%s), nil\n}\n\n", r, name, driverType, r)
+	fmt.Fprintf(&buf, "// Scan implements the sql.Scanner interface.\n")
+	fmt.Fprintf(&buf, "func (%s *%s) Scan(src interface{}) error {\nvar v %s\nswitch x := src.(type) {\n", r, name, name)
+	for _, typ := range scanTypes {
+		fmt.Fprintf(&buf, "case %s:\nv = %s(x)\n", typ, name)
+	}
+	fmt.Fprintf(&buf, "default:\nreturn fmt.Errorf(\"cannot scan %%T into %s\", src)\n}\n", name)
+	if members := uniqueEnumMembers(fa.enumGroups()); len(members) > 0 {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = m.Name
+		}
+		if fa.IsBitFlag() {
+			fmt.Fprintf(&buf, "if v&^(%s) != 0 {\nreturn fmt.Errorf(\"invalid %s value %%v\", v)\n}\n",
+				strings.Join(names, " | "), name)
+		} else {
+			fmt.Fprintf(&buf, "switch v {\ncase %s:\ndefault:\nreturn fmt.Errorf(\"invalid %s value %%v\", v)\n}\n",
+				strings.Join(names, ", "), name)
+		}
+	}
+	fmt.Fprintf(&buf, "*%s = v\nreturn nil\n}\n", r)
+	return formatCode(buf.Bytes())
+}
+
+// CheckExhaustive returns the cases missed by the switch statement, ignoring the default clause:
+// the enum members for a *ast.SwitchStmt over an enum-typed value,
+// or the sorted implementers in the initial packages, such as T or *T,
+// for a *ast.TypeSwitchStmt over an interface value.
+// The switch is exhaustive if the result is empty.
+// NOTE: Return false, if it is not a switch over an enum or an interface value of the program.
+func (prog *Program) CheckExhaustive(sw ast.Stmt) ([]string, bool) {
+	pkg, _, _ := prog.pathEnclosingInterval(sw.Pos(), sw.End())
+	if pkg == nil {
+		return nil, false
+	}
+	switch sw := sw.(type) {
+	case *ast.SwitchStmt:
+		return pkg.missingEnumCases(sw)
+	case *ast.TypeSwitchStmt:
+		return pkg.missingTypeCases(sw)
+	}
+	return nil, false
+}
+
+func (p *PackageInfo) missingEnumCases(sw *ast.SwitchStmt) ([]string, bool) {
+	if sw.Tag == nil {
+		return nil, false
+	}
+	named, ok := p.info.TypeOf(sw.Tag).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	fa, ok := p.prog.facadeOf(named.Obj())
+	if !ok {
+		return nil, false
+	}
+	groups := fa.enumGroups()
+	if len(groups) == 0 {
+		return nil, false
+	}
+	covered := make(map[string]bool)
+	for _, stmt := range sw.Body.List {
+		for _, expr := range stmt.(*ast.CaseClause).List {
+			if v := p.info.Types[expr].Value; v != nil {
+				covered[v.ExactString()] = true
+			}
+		}
+	}
+	var missing []string
+	for _, m := range uniqueEnumMembers(groups) {
+		if !covered[m.Value.ExactString()] {
+			missing = append(missing, m.Name)
+		}
+	}
+	return missing, true
+}
+
+func (p *PackageInfo) missingTypeCases(sw *ast.TypeSwitchStmt) ([]string, bool) {
+	var x ast.Expr
+	switch a := sw.Assign.(type) {
+	case *ast.ExprStmt:
+		x = a.X
+	case *ast.AssignStmt:
+		x = a.Rhs[0]
+	}
+	ta, ok := ast.Unparen(x).(*ast.TypeAssertExpr)
+	if !ok {
+		return nil, false
+	}
+	iface, ok := p.info.TypeOf(ta.X).Underlying().(*types.Interface)
+	if !ok {
+		return nil, false
+	}
+	var cases []types.Type
+	for _, clause := range p.TypeSwitchCases(sw) {
+		for _, tn := range clause {
+			if tn.Type != nil {
+				cases = append(cases, tn.Type)
+			}
+		}
+	}
+	covers := func(typ types.Type) bool {
+		for _, c := range cases {
+			if types.Identical(c, typ) || (types.IsInterface(c) && types.Implements(typ, c.Underlying().(*types.Interface))) {
+				return true
+			}
+		}
+		return false
+	}
+	qf := types.RelativeTo(p.Pkg)
+	var missing []string
+	for _, fa := range p.prog.implementers(iface) {
+		typ := fa.Object().Type()
+		if !types.Implements(typ, iface) {
+			typ = types.NewPointer(typ)
+		}
+		if !covers(typ) {
+			missing = append(missing, types.TypeString(typ, qf))
+		}
+	}
+	sort.Strings(missing)
+	return missing, true
+}
@@ -0,0 +1,288 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestEnumGroups(t *testing.T) {
+	var src = `package test
+type Color int
+const (
+	// Red doc
+	Red Color = iota // red comment
+	// Green doc
+	Green
+	Blue // blue comment
+)
+const (
+	X Color = 1
+	Y = 2
+)
+const Z = "z"
+`
+	prog, err := aster.LoadFile("../_out/enum.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := prog.Package("test").EnumGroups()
+	if len(groups) != 1 {
+		t.Fatalf("EnumGroups: want 1 group, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.Type.Name() != "Color" {
+		t.Fatalf("EnumGroups: want type Color, got %s", g.Type.Name())
+	}
+	var want = []aster.EnumMember{
+		{Name: "Red", Doc: "Red doc\n", Comment: "red comment\n"},
+		{Name: "Green", Doc: "Green doc\n"},
+		{Name: "Blue", Comment: "blue comment\n"},
+	}
+	if len(g.Members) != len(want) {
+		t.Fatalf("EnumGroups: want %d members, got %d", len(want), len(g.Members))
+	}
+	for i, m := range g.Members {
+		if m.Name != want[i].Name || m.Doc != want[i].Doc || m.Comment != want[i].Comment {
+			t.Fatalf("EnumGroups: member %d: want %+v, got %+v", i, want[i], *m)
+		}
+		if m.Value.String() != strconv.Itoa(i) {
+			t.Fatalf("EnumGroups: member %d: want value %d, got %s", i, i, m.Value)
+		}
+	}
+}
+
+func TestGenerateStringer(t *testing.T) {
+	var src = `package test
+import "strconv"
+var _ = strconv.Itoa
+type Perm uint8
+const (
+	None Perm = 0
+	Read Perm = 1 << iota
+	Write
+	Exec
+)
+type Color int
+const (
+	Red Color = iota
+	Green
+	Blue
+	Crimson = Red
+)
+`
+	prog, err := aster.LoadFile("../_out/stringer.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perm := prog.Lookup(aster.Typ, aster.Basic, "Perm")[0]
+	color := prog.Lookup(aster.Typ, aster.Basic, "Color")[0]
+	if !perm.IsBitFlag() || color.IsBitFlag() {
+		t.Fatal("IsBitFlag: want true only for Perm")
+	}
+	var code string
+	for _, fa := range []aster.Facade{perm, color} {
+		c, err := fa.GenerateStringer(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code += c + "\n"
+	}
+	t.Log(code)
+	for _, want := range []string{
+		`return "None"`,
+		`{Read, "Read"},`,
+		`s += "|"`,
+		`s += "Perm(0x" + strconv.FormatUint(uint64(rest), 16) + ")"`,
+		`return "Color(" + strconv.FormatInt(int64(c), 10) + ")"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateStringer: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, "Crimson") {
+		t.Fatal("GenerateStringer: want the duplicate value Crimson skipped")
+	}
+	mustCompile(t, src, code)
+}
+
+func TestGenerateStringerZeroAlloc(t *testing.T) {
+	var src = `package test
+import "strconv"
+var _ = strconv.Itoa
+type Level int8
+const (
+	Low Level = iota - 1
+	Mid
+	High
+)
+type Status uint16
+const (
+	OK Status = 200
+	NotFound Status = 404
+)
+type Perm uint8
+const (
+	Read Perm = 1 << iota
+	Write
+)
+`
+	prog, err := aster.LoadFile("../_out/stringer_zero_alloc.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code string
+	for _, name := range []string{"Level", "Status", "Perm"} {
+		fa := prog.Lookup(aster.Typ, aster.Basic, name)[0]
+		c, err := fa.GenerateStringer(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code += c + "\n"
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"i := l + 1",
+		"if i < 0 || i >= Level(len(_Level_index)-1) {",
+		"return _Level_name[_Level_index[i]:_Level_index[i+1]]",
+		`const _Level_name = "LowMidHigh"`,
+		"var _Level_index = [...]uint8{0, 3, 6, 10}",
+		"if s, ok := _Status_map[this]; ok {",
+		`NotFound: "NotFound",`,
+		`{Write, "Write"},`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateStringer: want %q in code", want)
+		}
+	}
+	mustCompile(t, src, code)
+}
+
+func TestGenerateSQLScanValuer(t *testing.T) {
+	var src = `package test
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+var _ = fmt.Errorf
+var _ driver.Valuer
+var _ sql.Scanner
+type Status string
+const (
+	Active   Status = "active"
+	Disabled Status = "disabled"
+)
+type Score float32
+type Perm uint8
+const (
+	Read Perm = 1 << iota
+	Write
+)
+`
+	prog, err := aster.LoadFile("../_out/sql_scan_valuer.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code string
+	for _, name := range []string{"Status", "Score", "Perm"} {
+		c, err := prog.Lookup(aster.Typ, aster.Basic, name)[0].GenerateSQLScanValuer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code += c + "\n"
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"func (s Status) Value() (driver.Value, error) {\n\treturn string(s), nil",
+		"case []byte:\n\t\tv = Status(x)",
+		"case Active, Disabled:",
+		"func (s *Score) Scan(src interface{}) error {",
+		"case int64:\n\t\tv = Score(x)",
+		"if v&^(Read|Write) != 0 {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateSQLScanValuer: want %q in code", want)
+		}
+	}
+	if strings.Count(code, "switch v {") != 1 {
+		t.Fatal("GenerateSQLScanValuer: want the enum check only for Status")
+	}
+	mustCompile(t, src+"var _ driver.Valuer = Status(\"\")\nvar _ sql.Scanner = new(Score)\n", code)
+}
+
+func TestCheckExhaustive(t *testing.T) {
+	var src = `package test
+type Color int
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+type Shape interface{ Area() float64 }
+type Square struct{}
+func (Square) Area() float64 { return 0 }
+type Circle struct{}
+func (*Circle) Area() float64 { return 0 }
+type Triangle struct{}
+func (Triangle) Area() float64 { return 0 }
+func f(c Color, s Shape, n int) {
+	switch c {
+	case Red:
+	case Green:
+	default:
+	}
+	switch s.(type) {
+	case Square:
+	case interface{ Sides() int }:
+	default:
+	}
+	switch n {
+	case 1:
+	}
+}
+`
+	prog, err := aster.LoadFile("../_out/check_exhaustive.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var switches []ast.Stmt
+	ast.Inspect(prog.Package("test").Files()[0].File, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			switches = append(switches, n.(ast.Stmt))
+		}
+		return true
+	})
+	if len(switches) != 3 {
+		t.Fatalf("want 3 switches, got %d", len(switches))
+	}
+	missing, ok := prog.CheckExhaustive(switches[0])
+	if !ok || strings.Join(missing, ",") != "Blue" {
+		t.Fatalf("CheckExhaustive(enum): want Blue, got %v, %v", missing, ok)
+	}
+	missing, ok = prog.CheckExhaustive(switches[1])
+	if !ok || strings.Join(missing, ",") != "*Circle,Triangle" {
+		t.Fatalf("CheckExhaustive(type): want *Circle and Triangle, got %v, %v", missing, ok)
+	}
+	if _, ok = prog.CheckExhaustive(switches[2]); ok {
+		t.Fatal("CheckExhaustive(int): want false")
+	}
+}
@@ -15,9 +15,13 @@
 package aster
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/ast"
 	"go/types"
+	"strconv"
 	"strings"
 )
 
@@ -34,6 +38,15 @@ type Facade interface {
 	// Ident returns the indent.
 	Ident() *ast.Ident
 
+	// DeclIndex returns the zero-based index of the top-level declaration
+	// which declares the facade among its file's declarations, or -1 if not found.
+	DeclIndex() int
+
+	// Decl returns the innermost *ast.GenDecl or *ast.FuncDecl enclosing the declaring identifier,
+	// such as the const block of a constant in a group.
+	// NOTE: Return nil, if not found.
+	Decl() ast.Decl
+
 	// Object returns the types.Object.
 	Object() types.Object
 
@@ -68,9 +81,38 @@ type Facade interface {
 	// Underlying returns the underlying type of a type.
 	Underlying() types.Type
 
+	// ShapeHash returns a stable hex hash of the underlying type's structure,
+	// i.e. the field names, types and tags of a struct, or the method signatures of an interface,
+	// independent of formatting, comments, field grouping and method order.
+	ShapeHash() string
+
+	// AnonymousForm returns the type expression of the struct or interface type without its name,
+	// such as `struct {...}`, with the tags and the comments within it as declared,
+	// which can be used as an anonymous type.
+	// NOTE:
+	//  Return error, if ObjKind != Typ, or TypKind != Struct and TypKind != Interface;
+	//  The types of the other packages are qualified by their package names.
+	AnonymousForm() (string, error)
+
 	// IsAlias reports whether obj is an alias name for a type.
 	IsAlias() bool
 
+	// ResolveAlias follows the alias chain, such as `type A = B; type B = C`,
+	// to the final defined type, and returns its facade.
+	// It returns fa itself if fa is not an alias.
+	// NOTE: Return false, if the final type is not a defined type of the program,
+	// or the chain is cyclic.
+	ResolveAlias() (Facade, bool)
+
+	// IsNamed reports whether the type of the facade is exactly the named type pkgPath.name,
+	// such as context.Context; pkgPath is empty for predeclared types, such as error.
+	IsNamed(pkgPath, name string) bool
+
+	// TypeArgs returns the type arguments of an instantiated named type,
+	// or of the instantiation which an alias denotes, such as `type IntStack = Stack[int]`.
+	// NOTE: return nil, if it is not an instantiated named type.
+	TypeArgs() []types.Type
+
 	// NumMethods returns the number of explicit methods whose receiver is named type t.
 	NumMethods() int
 
@@ -78,6 +120,22 @@ type Facade interface {
 	// NOTE: the result's TypKind is Signature.
 	Method(i int) Facade
 
+	// MethodByName returns the explicit method of named type t by name.
+	// NOTE: the result's TypKind is Signature.
+	MethodByName(name string) (Facade, bool)
+
+	// PromotedMethods returns the methods promoted from the embedded fields of the named type,
+	// including the embedded unexported types, which are in the method set of *T.
+	// NOTE: the result's TypKind is Signature.
+	PromotedMethods() []Facade
+
+	// MethodsJSON returns the method set of *T for the named type T as indented JSON,
+	// including the promoted methods, in the order of types.MethodSet, such as:
+	//  {"type": "T", "package": "path/to/pkg", "methods": [{"name": "M", ...}]}
+	// For an interface, it returns the interface methods.
+	// NOTE: Return error, if ObjKind != Typ
+	MethodsJSON() ([]byte, error)
+
 	// AssertableTo reports whether it can be asserted to have T's type.
 	AssertableTo(T Facade) bool
 
@@ -116,6 +174,17 @@ type Facade interface {
 	// NOTE: Panic, if TypKind != Basic
 	BasicKind() types.BasicKind
 
+	// Bits returns the bit width of the numeric basic type.
+	// The width of int, uint and uintptr depends on the sizes of the target platform.
+	// NOTE:
+	//  Panic, if TypKind != Basic;
+	//  Return false, if it is not a typed numeric type.
+	Bits() (int, bool)
+
+	// IsBitFlag reports whether the named integer type is a bit flag enum,
+	// whose constants are declared with the `<< iota` value expressions, such as `A Flag = 1 << iota`.
+	IsBitFlag() bool
+
 	// ----------------------------- TypKind = Signature (function) -----------------------------
 
 	// IsMethod returns whether it is a method.
@@ -142,6 +211,71 @@ type Facade interface {
 	// NOTE: Panic, if TypKind != Signature
 	Variadic() bool
 
+	// ResultType returns the results of the signature as a tuple,
+	// whose type expressions are those of the function or interface method declaration.
+	// NOTE: Panic, if TypKind != Signature
+	ResultType() *TupleType
+
+	// NamedResults returns the named results of the signature, such as n and err of `(n int, err error)`,
+	// with the type expressions of the declaration.
+	// NOTE: Return nil, if TypKind != Signature or the results are unnamed
+	NamedResults() []NamedResult
+
+	// HasContextFirst reports whether the first parameter of the function or method is of type context.Context.
+	// NOTE: Return false, if TypKind != Signature
+	HasContextFirst() bool
+
+	// AsInterfaceMethod returns the interface method field of the function or method,
+	// which has the same name and signature without receiver.
+	// NOTE: Return error, if ObjKind != Fun
+	AsInterfaceMethod() (*ast.Field, error)
+
+	// SetReceiverPointer changes the receiver type of the method to *T if ptr, or to T if not.
+	// The change is refused, if it would change the behavior of the method body:
+	// writing to the receiver or its fields and elements, taking their address, calling their pointer methods,
+	// or using the receiver other than to select, index or dereference it, such as passing it on.
+	// NOTE:
+	//  Return error, if it is not a method declared with a receiver of the defined type;
+	//  It only edits the AST, the type-checker deductions are not updated.
+	SetReceiverPointer(ptr bool) error
+
+	// HasRecover reports whether the body of the function or method calls the builtin recover,
+	// including in the function literals, such as `defer func() { recover() }()`.
+	// NOTE: Return false, if ObjKind != Fun or it has no body
+	HasRecover() bool
+
+	// DeferredCalls returns the functions called by the defer statements of the body in source order,
+	// such as "f.Close", "mu.Unlock" or "(func() literal)".
+	// The defer statements in the function literals are excluded.
+	// NOTE: Return nil, if ObjKind != Fun or it has no body
+	DeferredCalls() []string
+
+	// ReturnStatements returns the return statements of the function body in source order,
+	// excluding those of the function literals.
+	// NOTE: Return nil, if ObjKind != Fun or it has no body
+	ReturnStatements() []*ast.ReturnStmt
+
+	// ErrorResultIndex returns the index of the last result of type error, such as 1 for `(int, error)`,
+	// which is also the index of the error expression of a return statement listing all the results.
+	// NOTE: Return -1, if TypKind != Signature or it has no error result
+	ErrorResultIndex() int
+
+	// NeverReturns reports whether every path of the function body ends in a call which never returns,
+	// such as panic, os.Exit, log.Fatal and runtime.Goexit, in `select {}`,
+	// or in an infinite for loop without break and return.
+	// NOTE:
+	//  It is a conservative heuristic by walking the body, the calls of other functions are not followed;
+	//  Return false, if ObjKind != Fun or it has no body.
+	NeverReturns() bool
+
+	// RenameParam renames the parameter of the function or method,
+	// and its references in the body.
+	// NOTE:
+	//  Return error, if ObjKind != Fun, it has no body, or the parameter is not found;
+	//  Return error, if newName is invalid, or collides with a parameter, result, receiver or local,
+	//  or would shadow an outer object referenced in the body.
+	RenameParam(oldName, newName string) error
+
 	// ---------------------------------- TypKind = Struct ----------------------------------
 
 	// NumFields returns the number of fields in the struct (including blank and embedded fields).
@@ -159,6 +293,68 @@ type Facade interface {
 	// NOTE: Panic, if TypKind != Struct
 	FieldByName(name string) (field *StructField, found bool)
 
+	// FieldByPath returns the field by the dotted path of the field names, such as "Server.TLS.CertFile",
+	// walking through the nested struct fields and the pointers to them, and the type of the field.
+	// NOTE:
+	//  Panic, if TypKind != Struct;
+	//  Return false, if a field is not found, or an intermediate field is not a struct
+	//  of a defined type of the program or of an anonymous struct type.
+	FieldByPath(path string) (*StructField, TypeNode, bool)
+
+	// SortFields sorts the fields of the struct by less,
+	// keeping each field's doc and line comments with it.
+	// NOTE: Panic, if TypKind != Struct
+	SortFields(less func(a, b *StructField) bool)
+
+	// RemoveField removes the field by name, with its doc and line comments,
+	// and reports whether the field was found.
+	// NOTE: Panic, if TypKind != Struct
+	RemoveField(name string) bool
+
+	// Regroup merges the adjacent fields of the same type and tag into one field,
+	// such as `A int` and `B int` into `A, B int`, which is the reverse of the
+	// splitting done by editing a field of a group, or by expanding the groups on read.
+	// The fields with a doc or line comment between them are not merged.
+	// NOTE: Panic, if TypKind != Struct
+	Regroup()
+
+	// Diff compares the fields of the struct with the other struct by name,
+	// and returns the removed, retyped and retagged fields in the order of this struct,
+	// followed by the added fields in the order of the other struct.
+	// The types are compared by their expressions relative to their own packages.
+	// NOTE: Panic, if TypKind != Struct
+	Diff(other Facade) []FieldDiff
+
+	// MergeWith returns the code of a struct type which contains the fields of the struct
+	// followed by the other fields of the other struct, with their tags, docs and line comments,
+	// such as `struct {...}`.
+	// The fields of the same name are resolved by onConflict, which returns one of them,
+	// or nil to drop the field; the conflict is an error if onConflict is nil.
+	// NOTE:
+	//  Panic, if TypKind != Struct;
+	//  The code may require importing the packages of the other struct's field types.
+	MergeWith(other Facade, onConflict func(a, b *StructField) *StructField) (string, error)
+
+	// UnmarshalableFields returns the unexported fields which are silently skipped by encoding/json,
+	// excluding the blank fields, the fields tagged `json:"-"` and the embedded structs,
+	// whose exported fields are promoted.
+	// It returns nil if the type implements json.Marshaler or encoding.TextMarshaler.
+	// NOTE: Panic, if TypKind != Struct
+	UnmarshalableFields() []*StructField
+
+	// DuplicateTagNames returns the exported fields grouped by the wire names for the tag key,
+	// which are shared by two or more fields, such as two fields tagged `json:"id"`.
+	// The fields skipped by `key:"-"` are excluded. An empty result means no duplicates.
+	// NOTE: Panic, if TypKind != Struct
+	DuplicateTagNames(tagKey string) map[string][]*StructField
+
+	// ContainsNoCopy reports whether the struct must not be copied by value,
+	// as it contains a field, directly or in the nested struct and array fields,
+	// of a type with the Lock and Unlock methods, such as sync.Mutex,
+	// or of a known no-copy type, such as sync.WaitGroup.
+	// NOTE: Panic, if TypKind != Struct
+	ContainsNoCopy() bool
+
 	// ---------------------------------- TypKind = Interface ----------------------------------
 
 	// EmbeddedType returns the i'th embedded type of interface fa for 0 <= i < fa.NumEmbeddeds().
@@ -182,6 +378,145 @@ type Facade interface {
 	// IfaceNumExplicitMethods returns the number of explicitly declared methods of interface fa.
 	// NOTE: Panic, if TypKind != Interface
 	IfaceNumExplicitMethods() int
+
+	// IfaceMethodDoc returns the lead comment of the interface method by name,
+	// including the methods of embedded interfaces.
+	// NOTE: Panic, if TypKind != Interface
+	IfaceMethodDoc(name string) string
+
+	// IfaceAddMethod adds the method to the defined interface type,
+	// the signature is like `(key string) (string, error)`, with or without the func keyword.
+	// The Iface* methods of the facade reflect the edit, while the type-checked object type is unchanged.
+	// NOTE: Return error, if TypKind != Interface, or it is not a defined type,
+	// or the signature is invalid, or the method already exists.
+	IfaceAddMethod(name, signature string) error
+
+	// IfaceRemoveMethod removes the explicit method from the defined interface type by name,
+	// and reports whether it was found.
+	// The embedded interfaces are removed only by their type expression, such as `io.Reader`.
+	// NOTE: Panic, if TypKind != Interface
+	IfaceRemoveMethod(name string) bool
+
+	// ---------------------------------- Code Generation ----------------------------------
+
+	// GenerateToMap generates the ToMap and FromMap methods of the named struct type,
+	// which convert it to and from a map keyed by json tag name or field name.
+	// Fields tagged `json:"-"` are skipped.
+	// NOTE:
+	//  Return error, if TypKind != Struct or it is not a defined type;
+	//  The generated code requires importing the packages of the field types.
+	GenerateToMap() (string, error)
+
+	// GenerateValidate generates the Validate method of the named struct type,
+	// which enforces the rules of the `validate` tags:
+	// required, min=N and max=N (the value of numbers, or the length of strings, slices and maps),
+	// and len=N (the length of strings, slices and maps).
+	// Unknown rules, and the bounds the field types can not hold, generate TODO comments.
+	// NOTE:
+	//  Return error, if TypKind != Struct or it is not a defined type;
+	//  The generated code requires importing errors.
+	GenerateValidate() (string, error)
+
+	// GenerateTestSkeleton generates a table-driven test function of the package-level function,
+	// whose table has the args, the expected results and a wantErr column for the error result.
+	// NOTE:
+	//  Return error, if ObjKind != Fun, or it is a method or a generic function;
+	//  The generated code requires importing testing, and reflect if the function has non-error results.
+	GenerateTestSkeleton() (string, error)
+
+	// GenerateGoString generates the GoString method of the named struct type for the %#v verb,
+	// which renders the fields with their names and values, such as `pkg.T{A: 1, B: <nil>}`.
+	// The nested structs of the same package are rendered by their GoString methods,
+	// and the pointer fields by their pointees or <nil>.
+	// NOTE:
+	//  Return error, if TypKind != Struct or it is not a defined type;
+	//  The generated code requires importing fmt.
+	GenerateGoString() (string, error)
+
+	// GenerateCodec generates the encoding methods of the named struct type
+	// by the codec registered for the format.
+	// The builtin json codec generates the MarshalJSON and UnmarshalJSON methods like encoding/json,
+	// which require importing bytes, encoding/json and strings, and returns error
+	// for the embedded struct fields without json names or the string option.
+	// NOTE: Return error, if TypKind != Struct, it is not a defined type or the format is unknown.
+	GenerateCodec(format string) (string, error)
+
+	// JSONExample returns an indented example JSON object of the struct for documentation,
+	// keyed by the json tag names, or the field names, in the order of the fields.
+	// The values are the placeholders of the field types: false, 0, "", [] for slices and arrays,
+	// {} for maps, null for interfaces, and the example objects of the nested structs,
+	// whose embedded fields without json names are promoted like encoding/json.
+	// The omitempty fields are included, and the fields of the string option are quoted.
+	// NOTE: Return error, if TypKind != Struct
+	JSONExample() ([]byte, error)
+
+	// GenerateStringer generates the String method of the named integer enum type,
+	// which returns the constant names, or `T(N)` for an unknown value.
+	// For a bit flag enum, it returns the names of the set flags joined by "|",
+	// such as "Read|Write", followed by `T(0xN)` for the unknown bits.
+	// If zeroAlloc is true, String returns the known names of a non-bit-flag enum without allocation:
+	// a contiguous enum slices the _T_name string by the _T_index table, like the stringer tool,
+	// and a non-contiguous enum looks up the _T_map map.
+	// NOTE:
+	//  Return error, if it is not a defined integer type with constants in enum groups;
+	//  The generated code requires importing strconv.
+	GenerateStringer(zeroAlloc bool) (string, error)
+
+	// GenerateBuilder generates the TBuilder type of the named struct type T,
+	// with the NewTBuilder function, a WithF method per exported field F and the Build method.
+	// The methods of the slice fields take variadic parameters, and the methods of the pointer fields
+	// take the pointees, whose addresses are set.
+	// The fields with the `//aster:nobuild` directive are excluded.
+	// NOTE:
+	//  Return error, if TypKind != Struct or it is not a defined type;
+	//  The generated code requires importing the packages of the field types.
+	GenerateBuilder() (string, error)
+
+	// GenerateSQLScanValuer generates the Value method of driver.Valuer and the Scan method of sql.Scanner
+	// for the named basic type, which store it as the driver value of its kind:
+	// string, int64, float64 or bool. Scan also accepts []byte for a string type.
+	// For an enum type, Scan rejects the values other than the constants,
+	// or for a bit flag enum type, the values with bits other than the ones of the constants.
+	// NOTE:
+	//  Return error, if it is not a defined string, numeric or boolean type;
+	//  The generated code requires importing database/sql/driver and fmt.
+	GenerateSQLScanValuer() (string, error)
+
+	// GenerateMock generates the mock type of the interface type, named typeName,
+	// which has an OnM function field and an MCalls counter field per method M.
+	// The method M counts the call, and calls OnM if it is not nil, or returns the zero values.
+	// NOTE:
+	//  Return error, if TypKind != Interface, typeName is not a valid identifier,
+	//  or the interface has a type set (a constraint);
+	//  The generated code requires importing the packages of the types in the method signatures.
+	GenerateMock(typeName string) (string, error)
+
+	// GenerateOptions generates the functional options of the named struct type T:
+	// the option type named optTypeName as `func(*T)`, a WithF function per field F,
+	// and the Apply method of *T, which applies the options in order.
+	// The blank fields and the fields with the `//aster:nooption` directive are excluded.
+	// NOTE:
+	//  Return error, if TypKind != Struct, it is not a defined type, or optTypeName is not a valid identifier;
+	//  The generated code requires importing the packages of the field types.
+	GenerateOptions(optTypeName string) (string, error)
+
+	// AddGeneratedMethod parses the source of a method of the defined type, such as the generated code,
+	// and appends it to the file declaring the type, so that it becomes a method of the type.
+	// The receiver must be T or *T, and the method must not conflict with the fields and methods.
+	// NOTE:
+	//  Return error, if it is not a defined non-interface type, or the source is not a single method;
+	//  The signature is type-checked in the file scope of the type, but the body is not.
+	AddGeneratedMethod(src string) error
+
+	// AddGeneratedMethodTo parses the source of a method of the defined type like AddGeneratedMethod,
+	// but appends it to the file of the same package, such as the one created by NewGeneratedFile.
+	// NOTE:
+	//  Return error, if it is not a defined non-interface type, or the source is not a single method;
+	//  Return error, if the file is not of the package of the type;
+	//  The signature is type-checked in the file scope of the type, but the body is not;
+	//  The packages the method refers to in the file scope of the type are imported into the file, if missing,
+	//  and return error, if one of them can not be imported by the same name.
+	AddGeneratedMethodTo(file *File, src string) error
 }
 
 type facade struct {
@@ -189,7 +524,9 @@ type facade struct {
 	pkg          *PackageInfo
 	ident        *ast.Ident
 	doc          *ast.CommentGroup
-	structFields []*StructField // effective only for structure
+	structNode   *ast.StructType  // effective only for structure
+	structFields []*StructField   // effective only for structure
+	ifaceType    *types.Interface // effective only for interface edited by IfaceAddMethod or IfaceRemoveMethod
 }
 
 var _ Facade = (*facade)(nil)
@@ -217,6 +554,36 @@ func (fa *facade) Ident() *ast.Ident {
 	return fa.ident
 }
 
+// DeclIndex returns the zero-based index of the top-level declaration
+// which declares the facade among its file's declarations, or -1 if not found.
+func (fa *facade) DeclIndex() int {
+	pos := fa.ident.Pos()
+	if f := fa.pkg.fileOf(pos); f != nil {
+		for i, decl := range f.Decls {
+			if decl.Pos() <= pos && pos < decl.End() {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Decl returns the innermost *ast.GenDecl or *ast.FuncDecl enclosing the declaring identifier,
+// such as the const block of a constant in a group.
+// NOTE: Return nil, if not found.
+func (fa *facade) Decl() ast.Decl {
+	nodes, _ := fa.pkg.pathEnclosingInterval(fa.ident.Pos(), fa.ident.End())
+	for _, n := range nodes {
+		switch decl := n.(type) {
+		case *ast.GenDecl:
+			return decl
+		case *ast.FuncDecl:
+			return decl
+		}
+	}
+	return nil
+}
+
 // Object returns the types.Object.
 func (fa *facade) Object() types.Object {
 	return fa.obj
@@ -245,7 +612,7 @@ func (fa *facade) typ() types.Type {
 	if fa.typKind() == named {
 		return fa.obj.Type().Underlying()
 	}
-	return fa.obj.Type()
+	return types.Unalias(fa.obj.Type())
 }
 
 // Id is a wrapper for Id(obj.Pkg(), obj.Name()).
@@ -288,20 +655,159 @@ func (fa *facade) Underlying() types.Type {
 	return fa.typ().Underlying()
 }
 
+// ShapeHash returns a stable hex hash of the underlying type's structure,
+// i.e. the field names, types and tags of a struct, or the method signatures of an interface,
+// independent of formatting, comments, field grouping and method order.
+func (fa *facade) ShapeHash() string {
+	var buf bytes.Buffer
+	writeShape(&buf, fa.Underlying())
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// AnonymousForm returns the type expression of the struct or interface type without its name,
+// such as `struct {...}`, with the tags and the comments within it as declared,
+// which can be used as an anonymous type.
+// NOTE:
+//  Return error, if ObjKind != Typ, or TypKind != Struct and TypKind != Interface;
+//  The types of the other packages are qualified by their package names.
+func (fa *facade) AnonymousForm() (string, error) {
+	if fa.ObjKind() != Typ || (fa.TypKind() != Struct && fa.TypKind() != Interface) {
+		return "", fmt.Errorf("aster: %s is not a struct or interface type", fa.Name())
+	}
+	if spec := fa.typeSpec(); spec != nil {
+		switch spec.Type.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+			return fa.pkg.formatCommented(spec.Type)
+		}
+	}
+	return types.TypeString(fa.Underlying(), fa.nameQualifier()), nil
+}
+
+// typeSpec returns the declaration of the type, or nil.
+func (fa *facade) typeSpec() *ast.TypeSpec {
+	decl, ok := fa.Decl().(*ast.GenDecl)
+	if !ok {
+		return nil
+	}
+	for _, spec := range decl.Specs {
+		if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name == fa.ident {
+			return ts
+		}
+	}
+	return nil
+}
+
+func writeShape(buf *bytes.Buffer, typ types.Type) {
+	qf := func(p *types.Package) string { return p.Path() }
+	switch t := typ.(type) {
+	case *types.Struct:
+		buf.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if f.Embedded() {
+				buf.WriteString("embedded ")
+			}
+			buf.WriteString(f.Name())
+			buf.WriteByte(' ')
+			writeShape(buf, f.Type())
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.Quote(t.Tag(i)))
+			buf.WriteByte(';')
+		}
+		buf.WriteByte('}')
+	case *types.Interface:
+		// The method set of interface is complete and sorted by Id.
+		buf.WriteString("interface{")
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			buf.WriteString(m.Id())
+			buf.WriteString(signatureString(m.Type().(*types.Signature), qf))
+			buf.WriteByte(';')
+		}
+		buf.WriteByte('}')
+	default:
+		buf.WriteString(types.TypeString(typ, qf))
+	}
+}
+
 // IsAlias reports whether obj is an alias name for a type.
 func (fa *facade) IsAlias() bool {
-	t, ok := fa.getNamed()
+	t, ok := fa.obj.(*types.TypeName)
+	return ok && t.IsAlias()
+}
+
+// ResolveAlias follows the alias chain, such as `type A = B; type B = C`,
+// to the final defined type, and returns its facade.
+// It returns fa itself if fa is not an alias.
+// NOTE: Return false, if the final type is not a defined type of the program,
+// or the chain is cyclic.
+func (fa *facade) ResolveAlias() (Facade, bool) {
+	if !fa.IsAlias() {
+		return fa, true
+	}
+	typ := fa.obj.Type()
+	seen := make(map[*types.Alias]bool)
+	for {
+		a, ok := typ.(*types.Alias)
+		if !ok {
+			break
+		}
+		if seen[a] {
+			return nil, false
+		}
+		seen[a] = true
+		typ = a.Rhs()
+	}
+	t, ok := typ.(*types.Named)
 	if !ok {
+		return nil, false
+	}
+	final, ok := fa.pkg.prog.facadeOf(t.Obj())
+	if !ok {
+		return nil, false
+	}
+	return final, true
+}
+
+// IsNamed reports whether the type of the facade is exactly the named type pkgPath.name,
+// such as context.Context; pkgPath is empty for predeclared types, such as error.
+func (fa *facade) IsNamed(pkgPath, name string) bool {
+	return isNamed(fa.obj.Type(), pkgPath, name)
+}
+
+func isNamed(typ types.Type, pkgPath, name string) bool {
+	t, ok := types.Unalias(typ).(*types.Named)
+	if !ok || t.Obj().Name() != name {
 		return false
 	}
-	return t.Obj().IsAlias()
+	if pkg := t.Obj().Pkg(); pkg != nil {
+		return pkg.Path() == pkgPath
+	}
+	return pkgPath == ""
 }
 
 func (fa *facade) getNamed() (*types.Named, bool) {
 	if fa.typKind() != named {
 		return nil, false
 	}
-	return fa.obj.Type().(*types.Named), true
+	return types.Unalias(fa.obj.Type()).(*types.Named), true
+}
+
+// TypeArgs returns the type arguments of an instantiated named type,
+// or of the instantiation which an alias denotes, such as `type IntStack = Stack[int]`.
+// NOTE: return nil, if it is not an instantiated named type.
+func (fa *facade) TypeArgs() []types.Type {
+	t, ok := fa.getNamed()
+	if !ok || t.TypeArgs() == nil {
+		return nil
+	}
+	args := t.TypeArgs()
+	list := make([]types.Type, args.Len())
+	for i := range list {
+		list[i] = args.At(i)
+	}
+	return list
 }
 
 // NumMethods returns the number of explicit methods whose receiver is named type t.
@@ -314,13 +820,62 @@ func (fa *facade) NumMethods() int {
 }
 
 // Method returns the i'th method of named type t for 0 <= i < t.NumMethods().
+// The methods of an instantiated type are those declared by the generic type.
 // NOTE: the result's TypKind is Signature.
 func (fa *facade) Method(i int) Facade {
 	t, ok := fa.getNamed()
 	if !ok {
 		return nil
 	}
-	return fa.mustGetFacadeByObj(t.Method(i))
+	return fa.methodFacade(t.Method(i))
+}
+
+// MethodByName returns the explicit method of named type t by name.
+// The methods of an instantiated type are those declared by the generic type.
+// NOTE: the result's TypKind is Signature.
+func (fa *facade) MethodByName(name string) (Facade, bool) {
+	t, ok := fa.getNamed()
+	if !ok {
+		return nil, false
+	}
+	for i := 0; i < t.NumMethods(); i++ {
+		if m := t.Method(i); m.Name() == name {
+			return fa.methodFacade(m), true
+		}
+	}
+	return nil, false
+}
+
+// methodFacade returns the facade of the method declaration,
+// which may be in the imported package of the named type, such as bytes.Buffer.
+func (fa *facade) methodFacade(m *types.Func) *facade {
+	method, ok := fa.pkg.prog.facadeOf(m.Origin())
+	if !ok {
+		panic(fmt.Sprintf("aster: methodFacade can't find %s", m.String()))
+	}
+	return method
+}
+
+// PromotedMethods returns the methods promoted from the embedded fields of the named type,
+// including the embedded unexported types, which are in the method set of *T.
+// NOTE: the result's TypKind is Signature.
+func (fa *facade) PromotedMethods() []Facade {
+	t, ok := fa.getNamed()
+	if !ok || types.IsInterface(t) {
+		return nil
+	}
+	var list []Facade
+	mset := types.NewMethodSet(types.NewPointer(t))
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+		if len(sel.Index()) < 2 {
+			continue // declared directly
+		}
+		if method, ok := fa.pkg.prog.facadeOf(sel.Obj()); ok {
+			list = append(list, method)
+		}
+	}
+	return list
 }
 
 // AssertableTo reports whether it can be asserted to have T's type.
@@ -2,6 +2,9 @@ package aster_test
 
 import (
 	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
 	"testing"
 
 	"github.com/henrylee2cn/aster/aster"
@@ -113,3 +116,302 @@ func TestComment(t *testing.T) {
 // 		return true
 // 	})
 // }
+
+func TestTypeArgs(t *testing.T) {
+	var src = `package test
+type Stack[T any] struct {
+	items []T
+}
+type IntStack = Stack[int]
+type Pair[K comparable, V any] struct {
+	k K
+	v V
+}
+type StrPair = Pair[string, bool]
+type Plain struct{}
+`
+	prog, err := aster.LoadFile("../_out/type_args.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intStack := prog.Lookup(aster.Typ, 0, "IntStack")[0]
+	if !intStack.IsAlias() {
+		t.Fatal("IsAlias: IntStack want true")
+	}
+	args := intStack.TypeArgs()
+	if len(args) != 1 || args[0].String() != "int" {
+		t.Fatalf("TypeArgs of IntStack: want [int], got %v", args)
+	}
+	if u := intStack.Underlying().String(); u != "struct{items []int}" {
+		t.Fatalf("Underlying of IntStack: want struct{items []int}, got %s", u)
+	}
+	args = prog.Lookup(aster.Typ, 0, "StrPair")[0].TypeArgs()
+	if len(args) != 2 || args[0].String() != "string" || args[1].String() != "bool" {
+		t.Fatalf("TypeArgs of StrPair: want [string bool], got %v", args)
+	}
+	for _, name := range []string{"Stack", "Plain"} {
+		if args = prog.Lookup(aster.Typ, 0, name)[0].TypeArgs(); args != nil {
+			t.Fatalf("TypeArgs of %s: want nil, got %v", name, args)
+		}
+	}
+}
+
+func TestIsNamed(t *testing.T) {
+	var src = `package test
+import "context"
+type Context struct{}
+var ctx = context.Background()
+var local Context
+var e error
+`
+	prog, err := aster.LoadFile("../_out/is_named.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = []struct {
+		name    string
+		pkgPath string
+		typName string
+		want    bool
+	}{
+		{"ctx", "context", "Context", true},
+		{"local", "context", "Context", false},
+		{"local", "test", "Context", true},
+		{"e", "", "error", true},
+		{"e", "errors", "error", false},
+	}
+	for _, c := range cases {
+		fa := prog.Lookup(aster.Var, 0, c.name)[0]
+		if got := fa.IsNamed(c.pkgPath, c.typName); got != c.want {
+			t.Fatalf("IsNamed(%q, %q) of %s: want %v, got %v", c.pkgPath, c.typName, c.name, c.want, got)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	var src = `package test
+type A = B
+type B = C
+type C struct{}
+type D = []int
+`
+	prog, err := aster.LoadFile("../_out/resolve_alias.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := prog.Lookup(aster.Typ, 0, "A")[0]
+	final, ok := a.ResolveAlias()
+	if !ok || final.Name() != "C" || final.IsAlias() {
+		t.Fatalf("ResolveAlias(A): want C, got %v, %v", final, ok)
+	}
+	c := prog.Lookup(aster.Typ, 0, "C")[0]
+	if final, ok = c.ResolveAlias(); !ok || final != c {
+		t.Fatal("ResolveAlias(C): want C itself")
+	}
+	d := prog.Lookup(aster.Typ, 0, "D")[0]
+	if _, ok = d.ResolveAlias(); ok {
+		t.Fatal("ResolveAlias(D): want false for an unnamed type")
+	}
+}
+
+func TestDeclIndex(t *testing.T) {
+	var src = `package test
+import "fmt"
+type A struct {
+	X int
+}
+const (
+	B = 1
+	C = 2
+)
+func (A) D() { fmt.Println() }
+var E = 1
+`
+	prog, err := aster.LoadFile("../_out/decl_index.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]int{"A": 1, "B": 2, "C": 2, "D": 3, "E": 4}
+	for name, idx := range want {
+		fa := prog.Lookup(0, 0, name)[0]
+		if got := fa.DeclIndex(); got != idx {
+			t.Fatalf("DeclIndex of %s: want %d, got %d", name, idx, got)
+		}
+	}
+}
+
+func TestDecl(t *testing.T) {
+	var src = `package test
+const (
+	B = 1
+	C = 2
+)
+func D() {
+	var x int
+	_ = x
+}
+`
+	prog, err := aster.LoadFile("../_out/decl.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, ok := prog.Lookup(aster.Con, 0, "C")[0].Decl().(*ast.GenDecl)
+	if !ok || gen.Tok != token.CONST || len(gen.Specs) != 2 {
+		t.Fatalf("Decl of C: want the const block of 2 specs, got %#v", gen)
+	}
+	if prog.Lookup(aster.Con, 0, "B")[0].Decl() != gen {
+		t.Fatal("Decl of B: want the same const block as C")
+	}
+	fn, ok := prog.Lookup(aster.Fun, 0, "D")[0].Decl().(*ast.FuncDecl)
+	if !ok || fn.Name.Name != "D" {
+		t.Fatalf("Decl of D: want the FuncDecl, got %#v", fn)
+	}
+	local, ok := prog.Lookup(aster.Var, 0, "x")[0].Decl().(*ast.GenDecl)
+	if !ok || local.Tok != token.VAR {
+		t.Fatalf("Decl of x: want the local var declaration, got %#v", local)
+	}
+}
+
+func TestPromotedMethods(t *testing.T) {
+	var src = `package test
+type base struct{}
+func (base) Hello() string { return "" }
+func (*base) reset() {}
+type logger interface{ Log(string) }
+type Service struct {
+	base
+	logger
+}
+func (s *Service) Run() {}
+type Greeter interface{ Hello() string }
+var _ Greeter = Service{}
+`
+	prog, err := aster.LoadFile("../_out/promoted_methods.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "Service")[0]
+	var names []string
+	for _, m := range s.PromotedMethods() {
+		names = append(names, m.Name())
+	}
+	if strings.Join(names, ",") != "Hello,Log,reset" {
+		t.Fatalf("PromotedMethods: want Hello,Log,reset, got %v", names)
+	}
+	if s.NumMethods() != 1 || s.Method(0).Name() != "Run" {
+		t.Fatal("NumMethods: want only the declared method Run")
+	}
+}
+
+func TestShapeHash(t *testing.T) {
+	var src = `package test
+type A struct {
+	// X comment
+	X int ` + "`json:\"x\"`" + `
+	Y, Z int
+}
+type B struct{ X int ` + "`json:\"x\"`" + `; Y int; Z int }
+type C struct{ X int; Y int; Z int }
+type I interface {
+	Close() error
+	Read(p []byte) (n int, err error)
+}
+type Closer interface{ Close() error }
+type J interface{ Read(b []byte) (int, error); Closer }
+`
+	prog, err := aster.LoadFile("../_out/shape_hash.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := func(name string) string { return prog.Lookup(aster.Typ, 0, name)[0].ShapeHash() }
+	if a, b := hash("A"), hash("B"); a != b || len(a) != 64 {
+		t.Fatalf("ShapeHash: want A == B, got %s and %s", a, b)
+	}
+	if hash("A") == hash("C") {
+		t.Fatal("ShapeHash: want the tag to change the hash")
+	}
+	if i, j := hash("I"), hash("J"); i != j {
+		t.Fatalf("ShapeHash: want I == J, got %s and %s", i, j)
+	}
+}
+
+func TestExternalMethods(t *testing.T) {
+	var src = `package test
+import (
+	"bytes"
+	"io"
+)
+var _ io.Writer
+type W struct{ B bytes.Buffer }
+type Stack[T any] struct{ items []T }
+func (s *Stack[T]) Push(v T) { s.items = append(s.items, v) }
+type IntStack = Stack[int]
+`
+	prog, err := aster.LoadFile("../_out/external_methods.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := prog.Lookup(aster.Typ, aster.Struct, "W")[0]
+	field, _ := w.FieldByName("B")
+	buf, ok := field.ResolveType()
+	if !ok {
+		t.Fatal("ResolveType: want bytes.Buffer")
+	}
+	m, ok := buf.MethodByName("WriteString")
+	if !ok || m.Name() != "WriteString" || !m.IsMethod() {
+		t.Fatalf("MethodByName: want bytes.Buffer.WriteString, got %v", m)
+	}
+	for i := 0; i < buf.NumMethods(); i++ {
+		if buf.Method(i) == nil {
+			t.Fatalf("Method(%d): want a method of bytes.Buffer", i)
+		}
+	}
+	var writer aster.Facade
+	for _, fa := range prog.LookupAll(aster.Typ, aster.Interface, "Writer") {
+		if fa.Object().Pkg().Path() == "io" {
+			writer = fa
+		}
+	}
+	if !buf.Implements(writer, true) || buf.Implements(writer, false) {
+		t.Fatal("Implements: want only *bytes.Buffer to implement io.Writer")
+	}
+	intStack := prog.Lookup(aster.Typ, 0, "IntStack")[0]
+	if push, ok := intStack.MethodByName("Push"); !ok || push.Name() != "Push" {
+		t.Fatal("MethodByName: want Push of the instantiated Stack[int]")
+	}
+}
+
+func TestAnonymousForm(t *testing.T) {
+	var src = `package test
+import "io"
+// Point doc
+type Point struct {
+	X, Y int ` + "`json:\"x\"`" + `
+	// R doc
+	R io.Reader // line comment
+}
+type Closer interface{ Close() error }
+type P Point
+type N int
+`
+	prog, err := aster.LoadFile("../_out/anonymous_form.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"Point":  "struct {\n\tX, Y int `json:\"x\"`\n\t// R doc\n\tR io.Reader // line comment\n}",
+		"Closer": "interface{ Close() error }",
+		"P":      "struct{X int \"json:\\\"x\\\"\"; Y int \"json:\\\"x\\\"\"; R io.Reader}",
+	} {
+		got, err := prog.Lookup(aster.Typ, 0, name)[0].AnonymousForm()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("AnonymousForm(%s): want:\n%s\ngot:\n%s", name, want, got)
+		}
+	}
+	if _, err = prog.Lookup(aster.Typ, 0, "N")[0].AnonymousForm(); err == nil {
+		t.Fatal("AnonymousForm: want error for non-struct type")
+	}
+}
@@ -19,9 +19,14 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/henrylee2cn/goutil"
@@ -54,13 +59,16 @@ func (p *PackageInfo) Format() (codes map[string]string, first error) {
 		if first != nil {
 			return
 		}
-		codes[p.prog.filenames[f]] = code
+		codes[p.prog.filename(f)] = code
 	}
 	return
 }
 
 // FormatNode formats the node and returns the string.
 func (prog *Program) FormatNode(node ast.Node) (string, error) {
+	if f, ok := node.(*ast.File); ok && len(prog.appended[f]) > 0 {
+		return prog.formatAppended(f)
+	}
 	var dst bytes.Buffer
 	err := format.Node(&dst, prog.fset, node)
 	if err != nil {
@@ -69,15 +77,168 @@ func (prog *Program) FormatNode(node ast.Node) (string, error) {
 	return goutil.BytesToString(dst.Bytes()), nil
 }
 
+// formatAppended formats the file whose declarations are appended from other sources.
+// The comments are placed by their offsets in the source, so the appended declarations
+// are formatted with their own comments after the rest of the file.
+func (prog *Program) formatAppended(f *ast.File) (string, error) {
+	appendedFile := func(pos token.Pos) *token.File {
+		tf := prog.fset.File(pos)
+		for _, x := range prog.appended[f] {
+			if tf == x {
+				return tf
+			}
+		}
+		return nil
+	}
+	file := *f
+	file.Decls, file.Comments = nil, nil
+	var decls []ast.Decl
+	for _, decl := range f.Decls {
+		if appendedFile(decl.Pos()) != nil {
+			decls = append(decls, decl)
+		} else {
+			file.Decls = append(file.Decls, decl)
+		}
+	}
+	comments := make(map[*token.File][]*ast.CommentGroup)
+	for _, c := range f.Comments {
+		if tf := appendedFile(c.Pos()); tf != nil {
+			comments[tf] = append(comments[tf], c)
+		} else {
+			file.Comments = append(file.Comments, c)
+		}
+	}
+	var dst bytes.Buffer
+	if err := format.Node(&dst, prog.fset, &file); err != nil {
+		return "", err
+	}
+	for _, decl := range decls {
+		dst.WriteByte('\n')
+		node := &printer.CommentedNode{Node: decl, Comments: comments[prog.fset.File(decl.Pos())]}
+		if err := format.Node(&dst, prog.fset, node); err != nil {
+			return "", err
+		}
+		dst.WriteByte('\n')
+	}
+	return dst.String(), nil
+}
+
+// formatCommented formats the node of the package with the comments within it.
+func (p *PackageInfo) formatCommented(node ast.Node) (string, error) {
+	var comments []*ast.CommentGroup
+	if f := p.fileOf(node.Pos()); f != nil {
+		for _, c := range f.Comments {
+			if c.Pos() >= node.Pos() && c.End() <= node.End() {
+				comments = append(comments, c)
+			}
+		}
+	}
+	var dst bytes.Buffer
+	if err := format.Node(&dst, p.prog.fset, &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+		return "", err
+	}
+	return dst.String(), nil
+}
+
 // FormatNode formats the node and returns the string.
 func (p *PackageInfo) FormatNode(node ast.Node) (string, error) {
 	return p.prog.FormatNode(node)
 }
 
+// NodeText returns the original source text of the node as written,
+// including the comments and spacing within it, unlike FormatNode.
+// The source of the imported packages is read from the file system on first use.
+// NOTE: Return error, if the node has no valid positions, or the source can't be read.
+func (prog *Program) NodeText(node ast.Node) (string, error) {
+	pos, end := node.Pos(), node.End()
+	tf := prog.fset.File(pos)
+	if !pos.IsValid() || tf == nil || end < pos || int(end) > tf.Base()+tf.Size() {
+		return "", fmt.Errorf("aster: NodeText of node without valid positions: %T", node)
+	}
+	src, ok := prog.sources[tf]
+	if !ok {
+		var err error
+		src, err = os.ReadFile(tf.Name())
+		if err != nil {
+			return "", err
+		}
+		if len(src) != tf.Size() {
+			return "", fmt.Errorf("aster: the source of %s is changed", tf.Name())
+		}
+		prog.sources[tf] = src
+	}
+	return string(src[tf.Offset(pos):tf.Offset(end)]), nil
+}
+
+// NodeText returns the original source text of the node as written,
+// including the comments and spacing within it, unlike FormatNode.
+// NOTE: Return error, if the node has no valid positions, or the source can't be read.
+func (p *PackageInfo) NodeText(node ast.Node) (string, error) {
+	return p.prog.NodeText(node)
+}
+
+// Validate formats the created and imported packages codes, then re-parses and
+// re-type-checks them without writing, and returns the errors found.
+func (prog *Program) Validate() (errs []error) {
+	for _, pkg := range prog.InitialPackages() {
+		errs = append(errs, pkg.Validate()...)
+	}
+	return
+}
+
+// Validate formats the package codes, then re-parses and re-type-checks them
+// without writing, and returns the errors found.
+// NOTE:
+//  Soft type errors, such as unused variables, are ignored;
+//  The errors importing the packages that are not loaded, such as after RewriteImportPath, are ignored.
+func (p *PackageInfo) Validate() (errs []error) {
+	codes, err := p.Format()
+	if err != nil {
+		return []error{err}
+	}
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(codes))
+	for filename, code := range codes {
+		f, err := parser.ParseFile(fset, filename, code, parser.ParseComments)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files = append(files, f)
+	}
+	if errs != nil {
+		return
+	}
+	imp := p.prog.importer()
+	unloaded := make(map[token.Pos]bool)
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if _, err := imp.Import(path); err != nil {
+				unloaded[spec.Path.Pos()] = true
+			}
+		}
+	}
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if e, ok := err.(types.Error); !ok || !e.Soft && !unloaded[e.Pos] {
+				errs = append(errs, err)
+			}
+		},
+	}
+	conf.Check(p.Pkg.Path(), fset, files, nil)
+	return
+}
+
 // Rewrite formats the created and imported packages codes and writes to local files.
+// NOTE: Nothing will be written, if Validate fails.
 func (prog *Program) Rewrite() (first error) {
+	if errs := prog.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
 	for _, pkg := range prog.InitialPackages() {
-		first = pkg.Rewrite()
+		first = pkg.rewrite()
 		if first != nil {
 			return
 		}
@@ -86,7 +247,15 @@ func (prog *Program) Rewrite() (first error) {
 }
 
 // Rewrite formats the package codes and writes to local files.
+// NOTE: Nothing will be written, if Validate fails.
 func (p *PackageInfo) Rewrite() (first error) {
+	if errs := p.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	return p.rewrite()
+}
+
+func (p *PackageInfo) rewrite() (first error) {
 	codes, first := p.Format()
 	if first != nil {
 		return
@@ -100,6 +269,23 @@ func (p *PackageInfo) Rewrite() (first error) {
 	return
 }
 
+// WriteDir formats the package files and writes them into dir with their base names,
+// creating dir if needed.
+func (p *PackageInfo) WriteDir(dir string) (first error) {
+	for _, f := range p.files {
+		var code string
+		code, first = p.FormatNode(f)
+		if first != nil {
+			return
+		}
+		first = writeFile(filepath.Join(dir, filepath.Base(p.prog.filename(f))), code)
+		if first != nil {
+			return
+		}
+	}
+	return
+}
+
 // PrintResume prints the program resume.
 func (prog *Program) PrintResume() {
 	// Created packages are the initial packages specified by a call
@@ -0,0 +1,172 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestValidate(t *testing.T) {
+	var src = `package test
+import "strings"
+type S struct {
+	A int
+}
+var s = S{A: len(strings.TrimSpace(" a "))}
+`
+	var filename = "../_out/validate.go"
+	prog, err := aster.LoadFile(filename, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := prog.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+	// change the type of field A to string
+	for _, f := range prog.Package("test").Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if field, ok := n.(*ast.Field); ok && field.Names[0].Name == "A" {
+				field.Type.(*ast.Ident).Name = "string"
+			}
+			return true
+		})
+	}
+	errs := prog.Validate()
+	if len(errs) == 0 {
+		t.Fatal("Validate: want an error after changing the field type")
+	}
+	t.Log(errs)
+	os.Remove(filename)
+	if err = prog.Rewrite(); err == nil {
+		t.Fatal("Rewrite: want an error after changing the field type")
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("Rewrite: the invalid code is written")
+	}
+}
+
+func TestValidateUnloadedImport(t *testing.T) {
+	var src = `package test
+import "strings"
+var s = strings.TrimSpace(" a ")
+`
+	var filename = "../_out/validate_unloaded.go"
+	prog, err := aster.LoadFile(filename, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := prog.RewriteImportPath("strings", "example.com/newstrings"); n != 1 {
+		t.Fatalf("RewriteImportPath: want 1 import, got %d", n)
+	}
+	if errs := prog.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+	if err = prog.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `import "example.com/newstrings"`) {
+		t.Fatalf("Rewrite: want the new import path, got:\n%s", b)
+	}
+}
+
+func TestWriteDir(t *testing.T) {
+	var files = map[string]string{
+		"a.go": "package wd\n\n// A is a number.\ntype A int\n",
+		"b.go": "package wd\nvar B A\n",
+	}
+	srcDir := "../_out/write_dir"
+	if err := os.MkdirAll(srcDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog, err := aster.NewProgram().Import(srcDir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.InitialPackages()[0]
+	pkg.Lookup(aster.Typ, 0, "A")[0].CoverDoc("A is renamed.")
+	dir := filepath.Join(t.TempDir(), "out", "wd")
+	if err = pkg.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]string{
+		"a.go": "package wd\n\n// A is renamed.\ntype A int\n",
+		"b.go": "package wd\n\nvar B A\n",
+	}
+	for name, code := range want {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != code {
+			t.Fatalf("WriteDir %s: want:\n%s\ngot:\n%s", name, code, strings.TrimSpace(string(b)))
+		}
+	}
+}
+
+func TestNodeText(t *testing.T) {
+	var fn = `func Add(a,b int) int {
+	// keep   this comment
+	return a+b   // and this
+}`
+	var src = "package test\n\n// Add doc\n" + fn + "\n"
+	prog, err := aster.LoadFile("../_out/node_text.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	add := pkg.Lookup(aster.Fun, 0, "Add")[0]
+	text, err := pkg.NodeText(add.Decl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != fn {
+		t.Fatalf("NodeText: want:\n%s\ngot:\n%s", fn, text)
+	}
+	if _, err = pkg.NodeText(ast.NewIdent("x")); err == nil {
+		t.Fatal("NodeText: want error for node without positions")
+	}
+
+	dir := "../_out/node_text"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	prog, err = aster.NewProgram().Import(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg = prog.InitialPackages()[0]
+	text, err = pkg.NodeText(pkg.Lookup(aster.Fun, 0, "Add")[0].Decl())
+	if err != nil || text != fn {
+		t.Fatalf("NodeText of imported package: want:\n%s\ngot:\n%s, %v", fn, text, err)
+	}
+}
@@ -0,0 +1,765 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ---------------------------------- Code Generation ----------------------------------
+
+// GenerateToMap generates the ToMap and FromMap methods of the named struct type,
+// which convert it to and from a map keyed by json tag name or field name.
+// Fields tagged `json:"-"` are skipped.
+// NOTE:
+//  Return error, if TypKind != Struct or it is not a defined type;
+//  The generated code requires importing the packages of the field types.
+func (fa *facade) GenerateToMap() (string, error) {
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name, "m", "v")
+
+	fmt.Fprintf(&buf, "// ToMap converts %s to a map keyed by json tag name or field name.\n", name)
+	fmt.Fprintf(&buf, "func (%s *%s) ToMap() map[string]interface{} {\n", r, name)
+	fmt.Fprintf(&buf, "m := make(map[string]interface{}, %d)\n", s.NumFields())
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		key := field.WireName("json")
+		if key == "" {
+			continue
+		}
+		switch {
+		case fa.hasGeneratedMethod(field.obj.Type(), false):
+			fmt.Fprintf(&buf, "m[%q] = %s.%s.ToMap()\n", key, r, field.Name())
+		case fa.hasGeneratedMethod(field.obj.Type(), true):
+			fmt.Fprintf(&buf, "if %s.%s != nil {\nm[%q] = %s.%s.ToMap()\n}\n", r, field.Name(), key, r, field.Name())
+		default:
+			fmt.Fprintf(&buf, "m[%q] = %s.%s\n", key, r, field.Name())
+		}
+	}
+	buf.WriteString("return m\n}\n\n")
+
+	qf := fa.nameQualifier()
+	fmt.Fprintf(&buf, "// FromMap sets the fields of %s from a map keyed by json tag name or field name.\n", name)
+	fmt.Fprintf(&buf, "func (%s *%s) FromMap(m map[string]interface{}) {\n", r, name)
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		key := field.WireName("json")
+		if key == "" {
+			continue
+		}
+		typ := field.obj.Type()
+		switch {
+		case fa.hasGeneratedMethod(typ, false):
+			fmt.Fprintf(&buf, "if v, ok := m[%q].(map[string]interface{}); ok {\n%s.%s.FromMap(v)\n}\n", key, r, field.Name())
+		case fa.hasGeneratedMethod(typ, true):
+			fmt.Fprintf(&buf, "if v, ok := m[%q].(map[string]interface{}); ok {\n%s.%s = new(%s)\n%s.%s.FromMap(v)\n}\n",
+				key, r, field.Name(), types.TypeString(typ.(*types.Pointer).Elem(), qf), r, field.Name())
+		default:
+			fmt.Fprintf(&buf, "if v, ok := m[%q].(%s); ok {\n%s.%s = v\n}\n", key, types.TypeString(typ, qf), r, field.Name())
+		}
+	}
+	buf.WriteString("}\n")
+	return formatCode(buf.Bytes())
+}
+
+// GenerateValidate generates the Validate method of the named struct type,
+// which enforces the rules of the `validate` tags:
+// required, min=N and max=N (the value of numbers, or the length of strings, slices and maps),
+// and len=N (the length of strings, slices and maps).
+// Unknown rules, and the bounds the field types can not hold, generate TODO comments.
+// NOTE:
+//  Return error, if TypKind != Struct or it is not a defined type;
+//  The generated code requires importing errors.
+func (fa *facade) GenerateValidate() (string, error) {
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name)
+	fmt.Fprintf(&buf, "// Validate checks the fields of %s by the validate tags.\n", name)
+	fmt.Fprintf(&buf, "func (%s *%s) Validate() error {\n", r, name)
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		tag, err := field.Tags().Get("validate")
+		if err != nil {
+			continue
+		}
+		for _, rule := range append([]string{tag.Name}, tag.Options...) {
+			if rule != "" && rule != "-" {
+				writeValidateRule(&buf, r+"."+field.Name(), field.Name(), field.obj.Type(), rule)
+			}
+		}
+	}
+	buf.WriteString("return nil\n}\n")
+	return formatCode(buf.Bytes())
+}
+
+// GenerateTestSkeleton generates a table-driven test function of the package-level function,
+// whose table has the args, the expected results and a wantErr column for the error result.
+// NOTE:
+//  Return error, if ObjKind != Fun, or it is a method or a generic function;
+//  The generated code requires importing testing, and reflect if the function has non-error results.
+func (fa *facade) GenerateTestSkeleton() (string, error) {
+	if fa.ObjKind() != Fun || fa.IsMethod() {
+		return "", fmt.Errorf("aster: %s is not a package-level function", fa.Name())
+	}
+	sig := fa.signature()
+	if sig.TypeParams().Len() > 0 {
+		return "", fmt.Errorf("aster: generic function %s is not supported", fa.Name())
+	}
+	name := fa.Name()
+	testName := "Test" + strings.ToUpper(name[:1]) + name[1:]
+	params, results := sig.Params(), sig.Results()
+	hasErr := results.Len() > 0 && isNamed(results.At(results.Len()-1).Type(), "", "error")
+	numWants := results.Len()
+	if hasErr {
+		numWants--
+	}
+
+	qf := fa.nameQualifier()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s tests %s.\n", testName, name)
+	fmt.Fprintf(&buf, "func %s(t *testing.T) {\n", testName)
+	args := make([]string, params.Len())
+	if params.Len() > 0 {
+		buf.WriteString("type args struct {\n")
+		for i := 0; i < params.Len(); i++ {
+			v := params.At(i)
+			arg := v.Name()
+			if arg == "" || arg == "_" {
+				arg = fmt.Sprintf("arg%d", i)
+			}
+			args[i] = "tt.args." + arg
+			if sig.Variadic() && i == params.Len()-1 {
+				args[i] += "..."
+			}
+			fmt.Fprintf(&buf, "%s %s\n", arg, types.TypeString(v.Type(), qf))
+		}
+		buf.WriteString("}\n")
+	}
+	buf.WriteString("tests := []struct {\nname string\n")
+	if params.Len() > 0 {
+		buf.WriteString("args args\n")
+	}
+	gots := make([]string, 0, results.Len())
+	for i := 0; i < numWants; i++ {
+		fmt.Fprintf(&buf, "%s %s\n", wantName(i), types.TypeString(results.At(i).Type(), qf))
+		gots = append(gots, "got"+strings.TrimPrefix(wantName(i), "want"))
+	}
+	if hasErr {
+		buf.WriteString("wantErr bool\n")
+		gots = append(gots, "err")
+	}
+	buf.WriteString("}{\n// TODO: Add test cases.\n}\n")
+	buf.WriteString("for _, tt := range tests {\nt.Run(tt.name, func(t *testing.T) {\n")
+	call := fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	if len(gots) > 0 {
+		fmt.Fprintf(&buf, "%s := %s\n", strings.Join(gots, ", "), call)
+	} else {
+		buf.WriteString(call + "\n")
+	}
+	if hasErr {
+		fmt.Fprintf(&buf, "if (err != nil) != tt.wantErr {\nt.Errorf(\"%s() error = %%v, wantErr %%v\", err, tt.wantErr)\nreturn\n}\n", name)
+	}
+	for i := 0; i < numWants; i++ {
+		fmt.Fprintf(&buf, "if !reflect.DeepEqual(%s, tt.%s) {\nt.Errorf(\"%s() %s = %%v, want %%v\", %s, tt.%s)\n}\n",
+			gots[i], wantName(i), name, gots[i], gots[i], wantName(i))
+	}
+	buf.WriteString("})\n}\n}\n")
+	return formatCode(buf.Bytes())
+}
+
+// wantName returns the test table column of the i'th result, such as want, want1, want2.
+func wantName(i int) string {
+	if i == 0 {
+		return "want"
+	}
+	return "want" + strconv.Itoa(i)
+}
+
+// GenerateGoString generates the GoString method of the named struct type for the %#v verb,
+// which renders the fields with their names and values, such as `pkg.T{A: 1, B: <nil>}`.
+// The nested structs of the same package are rendered by their GoString methods,
+// and the pointer fields by their pointees or <nil>.
+// NOTE:
+//  Return error, if TypKind != Struct or it is not a defined type;
+//  The generated code requires importing fmt.
+func (fa *facade) GenerateGoString() (string, error) {
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name)
+	fmt.Fprintf(&buf, "// GoString renders %s with field names and values for the %%#v verb.\n", name)
+	fmt.Fprintf(&buf, "func (%s %s) GoString() string {\n", r, name)
+	fmt.Fprintf(&buf, "buf := []byte(%q)\n", fa.pkg.Pkg.Name()+"."+name+"{")
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		x := r + "." + field.Name()
+		sep := ", "
+		if i == 0 {
+			sep = ""
+		}
+		fmt.Fprintf(&buf, "buf = append(buf, %q...)\n", sep+field.Name()+": ")
+		typ := field.obj.Type()
+		switch {
+		case fa.hasGeneratedMethod(typ, false):
+			fmt.Fprintf(&buf, "buf = append(buf, %s.GoString()...)\n", x)
+		case fa.hasGeneratedMethod(typ, true):
+			fmt.Fprintf(&buf, "if %s == nil {\nbuf = append(buf, \"<nil>\"...)\n} else {\nbuf = append(buf, '&')\nbuf = append(buf, %s.GoString()...)\n}\n", x, x)
+		case GetTypKind(typ) == Pointer:
+			fmt.Fprintf(&buf, "if %s == nil {\nbuf = append(buf, \"<nil>\"...)\n} else {\nbuf = append(buf, fmt.Sprintf(\"&%%#v\", *%s)...)\n}\n", x, x)
+		default:
+			fmt.Fprintf(&buf, "buf = append(buf, fmt.Sprintf(\"%%#v\", %s)...)\n", x)
+		}
+	}
+	buf.WriteString("buf = append(buf, '}')\nreturn string(buf)\n}\n")
+	return formatCode(buf.Bytes())
+}
+
+// GenerateBuilder generates the TBuilder type of the named struct type T,
+// with the NewTBuilder function, a WithF method per exported field F and the Build method.
+// The methods of the slice fields take variadic parameters, and the methods of the pointer fields
+// take the pointees, whose addresses are set.
+// The fields with the `//aster:nobuild` directive are excluded.
+// NOTE:
+//  Return error, if TypKind != Struct or it is not a defined type;
+//  The generated code requires importing the packages of the field types.
+func (fa *facade) GenerateBuilder() (string, error) {
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	name := fa.Name()
+	builder := name + "Builder"
+	fmt.Fprintf(&buf, "// %s builds %s field by field.\n", builder, name)
+	fmt.Fprintf(&buf, "type %s struct {\nt %s\n}\n\n", builder, name)
+	fmt.Fprintf(&buf, "// New%s returns a %s of the zero %s.\n", builder, builder, name)
+	fmt.Fprintf(&buf, "func New%s() *%s {\nreturn &%s{}\n}\n\n", builder, builder, builder)
+	qf := fa.nameQualifier()
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		if !field.Exported() || field.HasDirective("aster:nobuild") {
+			continue
+		}
+		typ := field.obj.Type()
+		param, value := types.TypeString(typ, qf), "v"
+		switch t := typ.Underlying().(type) {
+		case *types.Slice:
+			param = "..." + types.TypeString(t.Elem(), qf)
+		case *types.Pointer:
+			param, value = types.TypeString(t.Elem(), qf), "&v"
+		}
+		fmt.Fprintf(&buf, "// With%s sets the field %s.\n", field.Name(), field.Name())
+		fmt.Fprintf(&buf, "func (b *%s) With%s(v %s) *%s {\nb.t.%s = %s\nreturn b\n}\n\n",
+			builder, field.Name(), param, builder, field.Name(), value)
+	}
+	fmt.Fprintf(&buf, "// Build returns the built %s.\n", name)
+	fmt.Fprintf(&buf, "func (b *%s) Build() *%s {\nt := b.t\nreturn &t\n}\n", builder, name)
+	return formatCode(buf.Bytes())
+}
+
+// GenerateMock generates the mock type of the interface type, named typeName,
+// which has an OnM function field and an MCalls counter field per method M.
+// The method M counts the call, and calls OnM if it is not nil, or returns the zero values.
+// NOTE:
+//  Return error, if TypKind != Interface, typeName is not a valid identifier,
+//  or the interface has a type set (a constraint);
+//  The generated code requires importing the packages of the types in the method signatures.
+func (fa *facade) GenerateMock(typeName string) (string, error) {
+	iface, ok := fa.typ().(*types.Interface)
+	if !ok {
+		return "", fmt.Errorf("aster: %s is not an interface type", fa.Name())
+	}
+	if !isValidIdentifier(typeName) {
+		return "", fmt.Errorf("aster: invalid mock type name %q", typeName)
+	}
+	if !iface.IsMethodSet() {
+		return "", fmt.Errorf("aster: constraint interface %s is not supported", fa.Name())
+	}
+	qf := fa.nameQualifier()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is a mock of %s, whose methods call the On functions if set.\n", typeName, fa.Name())
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		fmt.Fprintf(&buf, "On%s %s\n", m.Name(), types.TypeString(unnamedSignature(m.Type().(*types.Signature)), qf))
+		fmt.Fprintf(&buf, "%sCalls int\n", m.Name())
+	}
+	buf.WriteString("}\n")
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+		used := map[string]bool{"m": true}
+		params := make([]string, sig.Params().Len())
+		args := make([]string, sig.Params().Len())
+		for j := range params {
+			v := sig.Params().At(j)
+			name := v.Name()
+			if name == "" || name == "_" || used[name] {
+				name = "a" + strconv.Itoa(j)
+			}
+			used[name] = true
+			typ := types.TypeString(v.Type(), qf)
+			args[j] = name
+			if sig.Variadic() && j == len(params)-1 {
+				typ = "..." + types.TypeString(v.Type().(*types.Slice).Elem(), qf)
+				args[j] += "..."
+			}
+			params[j] = name + " " + typ
+		}
+		results := make([]string, sig.Results().Len())
+		for j := range results {
+			name := "r" + strconv.Itoa(j)
+			for used[name] {
+				name = "_" + name
+			}
+			results[j] = name + " " + types.TypeString(sig.Results().At(j).Type(), qf)
+		}
+		call := fmt.Sprintf("m.On%s(%s)", m.Name(), strings.Join(args, ", "))
+		fmt.Fprintf(&buf, "\n// %s implements %s.\n", m.Name(), fa.Name())
+		fmt.Fprintf(&buf, "func (m *%s) %s(%s) (%s) {\n", typeName, m.Name(), strings.Join(params, ", "), strings.Join(results, ", "))
+		fmt.Fprintf(&buf, "m.%sCalls++\nif m.On%s != nil {\n", m.Name(), m.Name())
+		if len(results) > 0 {
+			fmt.Fprintf(&buf, "return %s\n}\nreturn\n}\n", call)
+		} else {
+			fmt.Fprintf(&buf, "%s\n}\n}\n", call)
+		}
+	}
+	return formatCode(buf.Bytes())
+}
+
+// unnamedSignature returns the signature without the receiver and the parameter names.
+func unnamedSignature(sig *types.Signature) *types.Signature {
+	unnamed := func(t *types.Tuple) *types.Tuple {
+		vars := make([]*types.Var, t.Len())
+		for i := range vars {
+			vars[i] = types.NewParam(t.At(i).Pos(), t.At(i).Pkg(), "", t.At(i).Type())
+		}
+		return types.NewTuple(vars...)
+	}
+	return types.NewSignatureType(nil, nil, nil, unnamed(sig.Params()), unnamed(sig.Results()), sig.Variadic())
+}
+
+// GenerateOptions generates the functional options of the named struct type T:
+// the option type named optTypeName as `func(*T)`, a WithF function per field F,
+// and the Apply method of *T, which applies the options in order.
+// The blank fields and the fields with the `//aster:nooption` directive are excluded.
+// NOTE:
+//  Return error, if TypKind != Struct, it is not a defined type, or optTypeName is not a valid identifier;
+//  The generated code requires importing the packages of the field types.
+func (fa *facade) GenerateOptions(optTypeName string) (string, error) {
+	s, err := fa.namedStruct()
+	if err != nil {
+		return "", err
+	}
+	if !isValidIdentifier(optTypeName) {
+		return "", fmt.Errorf("aster: invalid option type name %q", optTypeName)
+	}
+	qf := fa.nameQualifier()
+	var buf bytes.Buffer
+	name := fa.Name()
+	r := receiverName(name, "o", "v")
+	fmt.Fprintf(&buf, "// %s sets an optional field of %s.\n", optTypeName, name)
+	fmt.Fprintf(&buf, "type %s func(*%s)\n\n", optTypeName, name)
+	for i := 0; i < s.NumFields(); i++ {
+		field := fa.Field(i)
+		if field.Name() == "_" || field.HasDirective("aster:nooption") {
+			continue
+		}
+		fn := "With" + upperFirst(field.Name())
+		fmt.Fprintf(&buf, "// %s sets the field %s.\n", fn, field.Name())
+		fmt.Fprintf(&buf, "func %s(v %s) %s {\nreturn func(%s *%s) {\n%s.%s = v\n}\n}\n\n",
+			fn, types.TypeString(field.obj.Type(), qf), optTypeName, r, name, r, field.Name())
+	}
+	fmt.Fprintf(&buf, "// Apply applies the options to %s in order.\n", name)
+	fmt.Fprintf(&buf, "func (%s *%s) Apply(opts ...%s) {\nfor _, o := range opts {\no(%s)\n}\n}\n", r, name, optTypeName, r)
+	return formatCode(buf.Bytes())
+}
+
+// AddGeneratedMethod parses the source of a method of the defined type, such as the generated code,
+// and appends it to the file declaring the type, so that it becomes a method of the type.
+// The receiver must be T or *T, and the method must not conflict with the fields and methods.
+// NOTE:
+//  Return error, if it is not a defined non-interface type, or the source is not a single method;
+//  The signature is type-checked in the file scope of the type, but the body is not.
+func (fa *facade) AddGeneratedMethod(src string) error {
+	file := fa.pkg.fileOf(fa.ident.Pos())
+	if file == nil {
+		return fmt.Errorf("aster: can't find the file of type %s", fa.Name())
+	}
+	return fa.addGeneratedMethod(file, src)
+}
+
+// AddGeneratedMethodTo parses the source of a method of the defined type like AddGeneratedMethod,
+// but appends it to the file of the same package, such as the one created by NewGeneratedFile.
+// NOTE:
+//  Return error, if it is not a defined non-interface type, or the source is not a single method;
+//  Return error, if the file is not of the package of the type;
+//  The signature is type-checked in the file scope of the type, but the body is not;
+//  The packages the method refers to in the file scope of the type are imported into the file, if missing,
+//  and return error, if one of them can not be imported by the same name.
+func (fa *facade) AddGeneratedMethodTo(file *File, src string) error {
+	if file == nil || file.pkg != fa.pkg {
+		return fmt.Errorf("aster: the file is not of the package of type %s", fa.Name())
+	}
+	return fa.addGeneratedMethod(file.File, src)
+}
+
+func (fa *facade) addGeneratedMethod(file *ast.File, src string) error {
+	t, ok := fa.getNamed()
+	if !ok || fa.ObjKind() != Typ || fa.IsAlias() || fa.TypKind() == Interface {
+		return fmt.Errorf("aster: %s is not a defined non-interface type", fa.Name())
+	}
+	fset := fa.pkg.prog.fset
+	code := "package p\n\n" + src
+	f, err := parser.ParseFile(fset, fset.File(file.Pos()).Name(), code, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if len(f.Decls) != 1 {
+		return fmt.Errorf("aster: want a single method, got %d declarations", len(f.Decls))
+	}
+	decl, ok := f.Decls[0].(*ast.FuncDecl)
+	if !ok || decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return fmt.Errorf("aster: want a method of %s", fa.Name())
+	}
+	recvExpr := decl.Recv.List[0].Type
+	star, isPtr := recvExpr.(*ast.StarExpr)
+	if isPtr {
+		recvExpr = star.X
+	}
+	if id, ok := recvExpr.(*ast.Ident); !ok || id.Name != fa.Name() {
+		return fmt.Errorf("aster: the receiver of method %s is not %s or *%s", decl.Name.Name, fa.Name(), fa.Name())
+	}
+	name := decl.Name.Name
+	if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, fa.pkg.Pkg, name); obj != nil {
+		return fmt.Errorf("aster: %s.%s already exists", fa.Name(), name)
+	}
+	tv, err := types.Eval(fset, fa.pkg.Pkg, fa.ident.Pos(), types.ExprString(decl.Type))
+	if err != nil {
+		return err
+	}
+	sig := tv.Type.(*types.Signature)
+	imports, err := fa.missingImports(file, decl)
+	if err != nil {
+		return err
+	}
+	for _, pkgName := range imports {
+		if pkgName.Name() == pkgName.Imported().Name() {
+			astutil.AddImport(fset, file, pkgName.Imported().Path())
+		} else {
+			astutil.AddNamedImport(fset, file, pkgName.Name(), pkgName.Imported().Path())
+		}
+	}
+
+	var recvType types.Type = t
+	if isPtr {
+		recvType = types.NewPointer(t)
+	}
+	recv := types.NewVar(decl.Recv.Pos(), fa.pkg.Pkg, "", recvType)
+	fn := types.NewFunc(decl.Name.Pos(), fa.pkg.Pkg, name,
+		types.NewSignatureType(recv, nil, nil, sig.Params(), sig.Results(), sig.Variadic()))
+	t.AddMethod(fn)
+	file.Decls = append(file.Decls, decl)
+	file.Comments = append(file.Comments, f.Comments...)
+	fa.pkg.prog.appended[file] = append(fa.pkg.prog.appended[file], fset.File(f.Pos()))
+	fa.pkg.prog.sources[fset.File(f.Pos())] = []byte(code)
+	fa.pkg.info.Defs[decl.Name] = fn
+	fa.pkg.addFacade(decl.Name, fn)
+	return nil
+}
+
+// missingImports returns the package names that decl refers to in the file scope of the type,
+// and that are not imported into file yet.
+func (fa *facade) missingImports(file *ast.File, decl *ast.FuncDecl) ([]*types.PkgName, error) {
+	pos := fa.ident.Pos()
+	scope := fa.pkg.Pkg.Scope().Innermost(pos)
+	if scope == nil {
+		return nil, nil
+	}
+	local := declaredNames(decl)
+	var pkgNames []*types.PkgName
+	seen := make(map[*types.PkgName]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && !local[id.Name] {
+			if _, obj := scope.LookupParent(id.Name, pos); obj != nil {
+				if pkgName, ok := obj.(*types.PkgName); ok && !seen[pkgName] {
+					seen[pkgName] = true
+					pkgNames = append(pkgNames, pkgName)
+				}
+			}
+		}
+		return true
+	})
+	var missing []*types.PkgName
+	for _, pkgName := range pkgNames {
+		imported := false
+		for _, spec := range file.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path != pkgName.Imported().Path() {
+				continue
+			}
+			name := pkgName.Imported().Name()
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == pkgName.Name() {
+				imported = true
+				break
+			}
+		}
+		if imported {
+			continue
+		}
+		if fa.pkg.nameTakenInFile(file, pkgName.Name()) {
+			return nil, fmt.Errorf("aster: can not import %q as %s into the file", pkgName.Imported().Path(), pkgName.Name())
+		}
+		missing = append(missing, pkgName)
+	}
+	return missing, nil
+}
+
+// declaredNames returns the names declared inside the function declaration.
+func declaredNames(decl *ast.FuncDecl) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Field:
+			for _, id := range x.Names {
+				names[id.Name] = true
+			}
+		case *ast.ValueSpec:
+			for _, id := range x.Names {
+				names[id.Name] = true
+			}
+		case *ast.TypeSpec:
+			names[x.Name.Name] = true
+		case *ast.AssignStmt:
+			if x.Tok == token.DEFINE {
+				for _, lhs := range x.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						names[id.Name] = true
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if x.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{x.Key, x.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						names[id.Name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// GeneratedFileHeader is the first line of the files created by NewGeneratedFile.
+const GeneratedFileHeader = "// Code generated by aster. DO NOT EDIT."
+
+// NewGeneratedFile creates an empty file of the package, with the GeneratedFileHeader and the package clause,
+// such as for the methods added by AddGeneratedMethodTo.
+// It is placed in the directory of the package files, and is written by WriteDir and Rewrite.
+// NOTE:
+//  Return the file of the package, if it already has one of the name;
+//  Return error, if the file can not be parsed, such as for an invalid package name.
+func (prog *Program) NewGeneratedFile(pkg *PackageInfo, name string) (*File, error) {
+	if len(pkg.files) > 0 {
+		name = filepath.Join(filepath.Dir(prog.filename(pkg.files[0])), name)
+	}
+	for _, f := range pkg.Files() {
+		if f.Filename == name {
+			return f, nil
+		}
+	}
+	src := GeneratedFileHeader + "\n\npackage " + pkg.Pkg.Name() + "\n"
+	f, err := parser.ParseFile(prog.fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	prog.sources[prog.fset.File(f.Pos())] = []byte(src)
+	prog.filenames[f] = name
+	pkg.files = append(pkg.files, f)
+	return &File{File: f, Filename: name, pkg: pkg}, nil
+}
+
+func writeValidateRule(buf *bytes.Buffer, x, fieldName string, typ types.Type, rule string) {
+	key, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		key, arg = rule[:i], rule[i+1:]
+	}
+	var cond, msg string
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			cond, msg = validateLenCond(key, arg, "len("+x+")")
+		case u.Info()&types.IsNumeric != 0 && key != "len":
+			cond, msg = validateValueCond(key, arg, x, u.Info())
+		}
+	case *types.Slice, *types.Map, *types.Array, *types.Chan:
+		cond, msg = validateLenCond(key, arg, "len("+x+")")
+	case *types.Pointer, *types.Interface, *types.Signature:
+		if key == "required" {
+			cond, msg = x+" == nil", "is required"
+		}
+	}
+	if cond == "" {
+		fmt.Fprintf(buf, "// TODO: unsupported validate rule %q of field %s\n", rule, fieldName)
+		return
+	}
+	fmt.Fprintf(buf, "if %s {\nreturn errors.New(%q)\n}\n", cond, fieldName+" "+msg)
+}
+
+func validateLenCond(key, arg, length string) (cond, msg string) {
+	if key == "required" {
+		return length + " == 0", "is required"
+	}
+	if !isValidateNumber(arg, true) {
+		return "", ""
+	}
+	switch key {
+	case "min":
+		return length + " < " + arg, "length must be at least " + arg
+	case "max":
+		return length + " > " + arg, "length must be at most " + arg
+	case "len":
+		return length + " != " + arg, "length must be " + arg
+	}
+	return "", ""
+}
+
+func validateValueCond(key, arg, x string, info types.BasicInfo) (cond, msg string) {
+	if key == "required" {
+		return x + " == 0", "is required"
+	}
+	if !isValidateNumber(arg, info&types.IsInteger != 0) ||
+		info&types.IsUnsigned != 0 && strings.HasPrefix(arg, "-") {
+		return "", ""
+	}
+	switch key {
+	case "min":
+		return x + " < " + arg, "must be at least " + arg
+	case "max":
+		return x + " > " + arg, "must be at most " + arg
+	}
+	return "", ""
+}
+
+func isValidateNumber(arg string, integer bool) bool {
+	if integer {
+		_, err := strconv.Atoi(arg)
+		return err == nil
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+// namedStruct returns the struct type of a defined struct type.
+func (fa *facade) namedStruct() (*types.Struct, error) {
+	if fa.ObjKind() != Typ || fa.IsAlias() || fa.typKind() != named {
+		return nil, fmt.Errorf("aster: %s is not a defined type", fa.Name())
+	}
+	s, ok := fa.typ().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("aster: %s is not a struct type", fa.Name())
+	}
+	fa.structure() // make sure initiated
+	return s, nil
+}
+
+// hasGeneratedMethod reports whether typ (or *typ if ptr) is a defined struct type
+// in the same package, whose conversion methods are expected to be generated too.
+func (fa *facade) hasGeneratedMethod(typ types.Type, ptr bool) bool {
+	if ptr {
+		p, ok := typ.(*types.Pointer)
+		if !ok {
+			return false
+		}
+		typ = p.Elem()
+	}
+	t, ok := typ.(*types.Named)
+	if !ok || t.Obj().Pkg() != fa.pkg.Pkg {
+		return false
+	}
+	_, ok = t.Underlying().(*types.Struct)
+	return ok
+}
+
+// typeString returns the type expression relative to the facade's package.
+func (fa *facade) typeString(typ types.Type) string {
+	return types.TypeString(typ, types.RelativeTo(fa.pkg.Pkg))
+}
+
+// nameQualifier returns the qualifier of the type expressions relative to the facade's package,
+// which qualifies the types of the other packages by their package names.
+func (fa *facade) nameQualifier() types.Qualifier {
+	return func(pkg *types.Package) string {
+		if pkg == fa.pkg.Pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+}
+
+// receiverName returns a receiver name for the type name,
+// which does not conflict with the reserved names.
+func receiverName(typeName string, reserved ...string) string {
+	first, _ := utf8.DecodeRuneInString(typeName)
+	r := string(unicode.ToLower(first))
+	for _, s := range reserved {
+		if r == s {
+			return "this"
+		}
+	}
+	return r
+}
+
+func formatCode(code []byte) (string, error) {
+	b, err := format.Source(code)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
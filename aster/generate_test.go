@@ -0,0 +1,509 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+// mustCompile checks that the generated code compiles together with src.
+func mustCompile(t *testing.T, src, code string) {
+	_, err := aster.LoadFile("../_out/generated.go", src+"\n"+code)
+	if err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, code)
+	}
+}
+
+func TestGenerateToMap(t *testing.T) {
+	var src = `package test
+import "net/url"
+type S struct {
+	A string ` + "`json:\"a\"`" + `
+	B int ` + "`json:\",omitempty\"`" + `
+	C []byte
+	D bool ` + "`json:\"-\"`" + `
+	Q url.Values
+	U *url.URL
+}
+type N int
+`
+	prog, err := aster.LoadFile("../_out/to_map.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "S")[0]
+	code, err := s.GenerateToMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{`m["a"] = s.A`, `m["B"] = s.B`, `m["C"].([]byte)`, `m["Q"].(url.Values)`, `m["U"].(*url.URL)`} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateToMap: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, `"D"`) {
+		t.Fatal("GenerateToMap: the skipped field D is generated")
+	}
+	mustCompile(t, src, code)
+
+	n := prog.Lookup(aster.Typ, aster.Basic, "N")[0]
+	if _, err = n.GenerateToMap(); err == nil {
+		t.Fatal("GenerateToMap: want error for non-struct type")
+	}
+}
+
+func TestGenerateValidate(t *testing.T) {
+	var src = `package test
+import "errors"
+var _ = errors.New
+type Config struct {
+	Name  string ` + "`validate:\"required,max=16\"`" + `
+	Port  int ` + "`validate:\"required,min=1,max=65535\"`" + `
+	Hosts []string ` + "`validate:\"min=1\"`" + `
+	Ratio float64 ` + "`validate:\"min=0.5\"`" + `
+	Email string ` + "`validate:\"email\"`" + `
+	Level int ` + "`validate:\"min=0.5\"`" + `
+	Count uint ` + "`validate:\"min=-1\"`" + `
+	Skip  int
+}
+`
+	prog, err := aster.LoadFile("../_out/validate.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := prog.Lookup(aster.Typ, aster.Struct, "Config")[0]
+	code, err := c.GenerateValidate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		`if len(c.Name) == 0 {`,
+		`if len(c.Name) > 16 {`,
+		`if c.Port == 0 {`,
+		`if c.Port < 1 {`,
+		`if c.Port > 65535 {`,
+		`if len(c.Hosts) < 1 {`,
+		`if c.Ratio < 0.5 {`,
+		`// TODO: unsupported validate rule "email" of field Email`,
+		`// TODO: unsupported validate rule "min=0.5" of field Level`,
+		`// TODO: unsupported validate rule "min=-1" of field Count`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateValidate: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, "Skip") {
+		t.Fatal("GenerateValidate: the field without rules is generated")
+	}
+	mustCompile(t, src, code)
+}
+
+func TestGenerateTestSkeleton(t *testing.T) {
+	var src = `package test
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+var _ = reflect.DeepEqual
+var _ testing.T
+func Split(s string, sep byte) ([]string, int, error) { return nil, 0, nil }
+func noop(_ int, args ...string) {}
+func Parse(raw string) (*url.URL, error) { return url.Parse(raw) }
+`
+	prog, err := aster.LoadFile("../_out/test_skeleton.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := prog.Lookup(aster.Fun, 0, "Split")[0]
+	code, err := split.GenerateTestSkeleton()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"func TestSplit(t *testing.T) {",
+		"s   string\n\t\tsep byte",
+		"want    []string\n\t\twant1   int\n\t\twantErr bool",
+		"got, got1, err := Split(tt.args.s, tt.args.sep)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateTestSkeleton: want %q in code", want)
+		}
+	}
+	mustCompile(t, src, code)
+
+	noop := prog.Lookup(aster.Fun, 0, "noop")[0]
+	code, err = noop.GenerateTestSkeleton()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(code, "noop(tt.args.arg0, tt.args.args...)") {
+		t.Fatalf("GenerateTestSkeleton: want the variadic call in code:\n%s", code)
+	}
+	mustCompile(t, src, code)
+
+	parse := prog.Lookup(aster.Fun, 0, "Parse")[0]
+	code, err = parse.GenerateTestSkeleton()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(code, "want    *url.URL") {
+		t.Fatalf("GenerateTestSkeleton: want the result type *url.URL in code:\n%s", code)
+	}
+	mustCompile(t, src, code)
+}
+
+func TestGenerateGoString(t *testing.T) {
+	var src = `package test
+import "fmt"
+var _ = fmt.Sprint
+type Inner struct {
+	X int
+}
+type Outer struct {
+	Name  string
+	In    Inner
+	Next  *Inner
+	Count *int
+}
+`
+	prog, err := aster.LoadFile("../_out/go_string.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code string
+	for _, name := range []string{"Inner", "Outer"} {
+		s := prog.Lookup(aster.Typ, aster.Struct, name)[0]
+		c, err := s.GenerateGoString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code += c + "\n"
+	}
+	t.Log(code)
+	for _, want := range []string{
+		`func (o Outer) GoString() string {`,
+		`buf := []byte("test.Outer{")`,
+		`buf = append(buf, o.In.GoString()...)`,
+		`buf = append(buf, o.Next.GoString()...)`,
+		`buf = append(buf, fmt.Sprintf("&%#v", *o.Count)...)`,
+		`buf = append(buf, "<nil>"...)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateGoString: want %q in code", want)
+		}
+	}
+	mustCompile(t, src, code)
+}
+
+func TestGenerateBuilder(t *testing.T) {
+	var src = `package test
+import "net/url"
+type Tags []string
+type Server struct {
+	Addr string
+	Port *int
+	Tags Tags
+	Proxy *url.URL
+	Query url.Values
+	// Cache is built lazily.
+	//aster:nobuild
+	Cache map[string]string
+	conns int
+}
+`
+	prog, err := aster.LoadFile("../_out/builder.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "Server")[0]
+	code, err := s.GenerateBuilder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"type ServerBuilder struct {",
+		"func NewServerBuilder() *ServerBuilder {",
+		"func (b *ServerBuilder) WithAddr(v string) *ServerBuilder {",
+		"func (b *ServerBuilder) WithPort(v int) *ServerBuilder {\n\tb.t.Port = &v",
+		"func (b *ServerBuilder) WithTags(v ...string) *ServerBuilder {",
+		"func (b *ServerBuilder) WithProxy(v url.URL) *ServerBuilder {",
+		"func (b *ServerBuilder) WithQuery(v url.Values) *ServerBuilder {",
+		"func (b *ServerBuilder) Build() *Server {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateBuilder: want %q in code", want)
+		}
+	}
+	for _, skipped := range []string{"WithCache", "conns"} {
+		if strings.Contains(code, skipped) {
+			t.Fatalf("GenerateBuilder: want %s skipped", skipped)
+		}
+	}
+	mustCompile(t, src, code)
+}
+
+func TestAddGeneratedMethod(t *testing.T) {
+	var src = `package test
+import "strconv"
+// Point is a point.
+type Point struct {
+	X, Y int
+}
+// Origin is the origin.
+var Origin Point
+type Stringer interface{ String() string }
+`
+	prog, err := aster.LoadFile("../_out/add_generated_method.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := prog.Lookup(aster.Typ, aster.Struct, "Point")[0]
+	err = point.AddGeneratedMethod(`// String formats the point.
+func (p Point) String() string {
+	// x first
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := point.MethodByName("String")
+	if !ok || !m.IsMethod() || m.Doc() != "String formats the point.\n" {
+		t.Fatalf("MethodByName(String): got %v, %v", m, ok)
+	}
+	if m.Results().Len() != 1 || m.Results().At(0).Type().String() != "string" {
+		t.Fatalf("String: want result string, got %v", m.Results())
+	}
+	if !point.Implements(prog.Lookup(aster.Typ, 0, "Stringer")[0], false) {
+		t.Fatal("Implements(Stringer): want true")
+	}
+	for _, bad := range []string{
+		"func (p Point) String() string { return \"\" }",
+		"func (o Other) Get() int { return 0 }",
+		"func F() {}",
+		"func (p *Point) Scale(k Unknown) {}",
+	} {
+		if err = point.AddGeneratedMethod(bad); err == nil {
+			t.Fatalf("AddGeneratedMethod(%q): want error", bad)
+		}
+	}
+	pkg := prog.Package("test")
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	var want = `
+type Stringer interface{ String() string }
+
+// String formats the point.
+func (p Point) String() string {
+	// x first
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+}
+`
+	if !strings.HasSuffix(code, want) {
+		t.Fatalf("AddGeneratedMethod: want suffix:\n%s\ngot:\n%s", want, code)
+	}
+	if errs := pkg.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+}
+
+func TestNewGeneratedFile(t *testing.T) {
+	var src = `package test
+import "io"
+var _ io.Writer
+type Point struct {
+	X, Y int
+}
+`
+	prog, err := aster.LoadFile("../_out/generated_file/point.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	file, err := prog.NewGeneratedFile(pkg, "point_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, err := prog.NewGeneratedFile(pkg, "point_gen.go"); err != nil || same.File != file.File || len(pkg.Files()) != 2 {
+		t.Fatal("NewGeneratedFile: want the same file for the same name")
+	}
+	point := prog.Lookup(aster.Typ, aster.Struct, "Point")[0]
+	for _, method := range []string{
+		"// Sum returns X+Y.\nfunc (p Point) Sum() int { return p.X + p.Y }",
+		"// Scale scales the point.\nfunc (p *Point) Scale(k int) {\n\tp.X *= k\n\tp.Y *= k\n}",
+		"// WriteTo writes the point to w.\nfunc (p Point) WriteTo(w io.Writer) (int64, error) {\n\tn, err := io.WriteString(w, \"point\")\n\treturn int64(n), err\n}",
+	} {
+		if err = point.AddGeneratedMethodTo(file, method); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := point.MethodByName("Scale"); !ok {
+		t.Fatal("MethodByName(Scale): want found")
+	}
+	if errs := pkg.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+	dir := "../_out/generated_file/out"
+	if err = pkg.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "point_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = aster.GeneratedFileHeader + `
+
+package test
+
+import "io"
+
+// Sum returns X+Y.
+func (p Point) Sum() int { return p.X + p.Y }
+
+// Scale scales the point.
+func (p *Point) Scale(k int) {
+	p.X *= k
+	p.Y *= k
+}
+
+// WriteTo writes the point to w.
+func (p Point) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, "point")
+	return int64(n), err
+}
+`
+	if string(b) != want {
+		t.Fatalf("NewGeneratedFile: want:\n%s\ngot:\n%s", want, b)
+	}
+}
+
+func TestGenerateMock(t *testing.T) {
+	var src = `package test
+import "io"
+var _ io.Reader
+type Store interface {
+	Get(key string) (io.Reader, error)
+	Log(format string, args ...interface{})
+}
+`
+	prog, err := aster.LoadFile("../_out/mock.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := prog.Lookup(aster.Typ, aster.Interface, "Store")[0]
+	code, err := store.GenerateMock("MockStore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"OnGet    func(string) (io.Reader, error)",
+		"GetCalls int",
+		"func (m *MockStore) Get(key string) (r0 io.Reader, r1 error) {",
+		"return m.OnGet(key)",
+		"func (m *MockStore) Log(format string, args ...interface{}) {",
+		"m.OnLog(format, args...)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateMock: want %q in code", want)
+		}
+	}
+	mustCompile(t, src, code+"\nvar _ Store = (*MockStore)(nil)\n")
+
+	if _, err = store.GenerateMock("1Mock"); err == nil {
+		t.Fatal("GenerateMock: want error for invalid type name")
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	var src = `package test
+import "time"
+type Config struct {
+	Addr    string
+	timeout time.Duration
+	Hosts   []string
+	//aster:nooption
+	Internal int
+	_        int
+}
+`
+	prog, err := aster.LoadFile("../_out/options.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := prog.Lookup(aster.Typ, aster.Struct, "Config")[0]
+	code, err := cfg.GenerateOptions("Option")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"type Option func(*Config)",
+		"func WithAddr(v string) Option {",
+		"func WithTimeout(v time.Duration) Option {",
+		"c.timeout = v",
+		"func WithHosts(v []string) Option {",
+		"func (c *Config) Apply(opts ...Option) {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateOptions: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, "Internal") {
+		t.Fatal("GenerateOptions: the field with the nooption directive is generated")
+	}
+	mustCompile(t, src, code)
+
+	src = `package test
+type Volume struct {
+	Size int
+	ñame string
+}
+`
+	prog, err = aster.LoadFile("../_out/options_volume.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err = prog.Lookup(aster.Typ, aster.Struct, "Volume")[0].GenerateOptions("Option")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"func WithSize(v int) Option {\n\treturn func(this *Volume) {\n\t\tthis.Size = v",
+		"func WithÑame(v string) Option {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("GenerateOptions: want %q in code", want)
+		}
+	}
+	mustCompile(t, src, code)
+
+	if _, err = cfg.GenerateOptions("opt-type"); err == nil {
+		t.Fatal("GenerateOptions: want error for invalid type name")
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// RewriteImportPath rewrites the imports of path old, or with the prefix old/,
+// to the new path in the initial packages, and returns the number of changed imports.
+//
+// If an import has no explicit name and its package name changes,
+// the local usages are renamed to the new name, which is the name of the new package if loaded,
+// or else the new last path segment;
+// the old name is kept by an explicit import name, if the new segment is a major version suffix
+// such as v2, is not a valid identifier, or is taken in the file.
+func (prog *Program) RewriteImportPath(old, new string) int {
+	var count int
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			for _, imp := range f.Imports {
+				oldPath, err := strconv.Unquote(imp.Path.Value)
+				if err != nil || (oldPath != old && !strings.HasPrefix(oldPath, old+"/")) {
+					continue
+				}
+				newPath := new + oldPath[len(old):]
+				imp.Path.Value = strconv.Quote(newPath)
+				count++
+				if imp.Name == nil {
+					pkg.renameImplicitImport(f, imp, path.Base(oldPath), newPath)
+				}
+			}
+		}
+	}
+	return count
+}
+
+// Importers returns the loaded packages, including the dependencies,
+// whose files import the package of pkgPath, in the order of package path.
+func (prog *Program) Importers(pkgPath string) []*PackageInfo {
+	pkgs := make(map[*PackageInfo]bool, len(prog.allPackages)+len(prog.created))
+	for _, pkg := range prog.allPackages {
+		pkgs[pkg] = true
+	}
+	for _, pkg := range prog.created {
+		pkgs[pkg] = true
+	}
+	var list []*PackageInfo
+	for pkg := range pkgs {
+	files:
+		for _, f := range pkg.files {
+			for _, imp := range f.Imports {
+				if p, _ := strconv.Unquote(imp.Path.Value); p == pkgPath {
+					list = append(list, pkg)
+					break files
+				}
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pkg.Path() < list[j].Pkg.Path()
+	})
+	return list
+}
+
+// renameImplicitImport renames the usages of the import without explicit name to the package name of newPath,
+// if its package name follows the last path segment oldBase or the new package is loaded.
+func (p *PackageInfo) renameImplicitImport(f *ast.File, imp *ast.ImportSpec, oldBase, newPath string) {
+	pkgName, ok := p.info.Implicits[imp].(*types.PkgName)
+	if !ok {
+		return
+	}
+	newName := path.Base(newPath)
+	if info := p.prog.Package(newPath); info != nil {
+		newName = info.Pkg.Name()
+	} else if pkgName.Name() != oldBase {
+		return
+	} else if isMajorVersion(newName) {
+		newName = "" // the package name is not the version suffix
+	}
+	if newName == pkgName.Name() {
+		return
+	}
+	var ids []*ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && p.info.Uses[id] == pkgName {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	taken := !isValidIdentifier(newName) || p.nameTakenInFile(f, newName)
+	for _, id := range ids {
+		if taken {
+			break
+		}
+		if scope := p.Pkg.Scope().Innermost(id.Pos()); scope != nil {
+			_, obj := scope.LookupParent(newName, id.Pos())
+			taken = obj != nil
+		}
+	}
+	if taken {
+		imp.Name = ast.NewIdent(pkgName.Name())
+		return
+	}
+	for _, id := range ids {
+		id.Name = newName
+	}
+}
+
+// isMajorVersion reports whether the last path segment is a major version suffix, such as v2.
+func isMajorVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' || segment[1] == '0' {
+		return false
+	}
+	for _, c := range segment[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UpgradeCalls rewrites the calls of the functions keyed by the qualified names in mapping,
+// such as "io/ioutil.ReadAll", to the functions of the mapped qualified names, such as "io.ReadAll",
+// in the initial packages, and returns the number of the rewritten calls.
+// The imports of the new functions are added, and the imports left unused are removed.
+// NOTE:
+//  Return error without rewriting, if a new function is not of a package of the program;
+//  The arguments are kept, so the new functions should have compatible signatures;
+//  It only edits the AST, the type-checker deductions are not updated.
+func (prog *Program) UpgradeCalls(mapping map[string]string) (int, error) {
+	olds := make([]string, 0, len(mapping))
+	for old := range mapping {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	funcs := make(map[string]*types.Func, len(mapping))
+	for _, old := range olds {
+		newName := mapping[old]
+		i := strings.LastIndexByte(newName, '.')
+		if i <= 0 {
+			return 0, fmt.Errorf("aster: %q is not a qualified function name", newName)
+		}
+		info := prog.Package(newName[:i])
+		if info == nil {
+			return 0, fmt.Errorf("aster: the package of %s is not loaded", newName)
+		}
+		fn, ok := info.Pkg.Scope().Lookup(newName[i+1:]).(*types.Func)
+		if !ok {
+			return 0, fmt.Errorf("aster: %s is not a function", newName)
+		}
+		funcs[old] = fn
+	}
+	var count int
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			n, oldPaths := pkg.upgradeCalls(f, funcs)
+			if n == 0 {
+				continue
+			}
+			count += n
+			pkg.removeUnusedImports(f, oldPaths)
+		}
+	}
+	return count, nil
+}
+
+// upgradeCalls rewrites the calls of file f to the new functions keyed by the qualified names of the old ones,
+// and returns the number of the rewritten calls and the package paths of the old functions.
+func (p *PackageInfo) upgradeCalls(f *ast.File, funcs map[string]*types.Func) (int, map[string]bool) {
+	var count int
+	oldPaths := make(map[string]bool)
+	qf := p.importQualifier(f)
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var id *ast.Ident
+		switch fn := ast.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = fn
+		case *ast.SelectorExpr:
+			id = fn.Sel
+		default:
+			return true
+		}
+		obj, ok := p.info.Uses[id].(*types.Func)
+		if !ok || obj.Pkg() == nil || obj.Type().(*types.Signature).Recv() != nil {
+			return true
+		}
+		newFunc, ok := funcs[obj.Pkg().Path()+"."+obj.Name()]
+		if !ok {
+			return true
+		}
+		pos := call.Fun.Pos()
+		var fun ast.Expr = &ast.Ident{NamePos: pos, Name: newFunc.Name()}
+		if q := qf(newFunc.Pkg()); q != "" {
+			fun = &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: q}, Sel: fun.(*ast.Ident)}
+		}
+		call.Fun = fun
+		oldPaths[obj.Pkg().Path()] = true
+		count++
+		return true
+	})
+	return count, oldPaths
+}
+
+// removeUnusedImports removes the imports of the paths from file f,
+// which are no longer used by the identifiers of the file.
+func (p *PackageInfo) removeUnusedImports(f *ast.File, paths map[string]bool) {
+	used := make(map[*types.PkgName]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if pkgName, ok := p.info.Uses[id].(*types.PkgName); ok {
+				used[pkgName] = true
+			}
+		}
+		return true
+	})
+	for _, spec := range append([]*ast.ImportSpec(nil), f.Imports...) {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if !paths[importPath] {
+			continue
+		}
+		var name string
+		pkgName, ok := p.info.Implicits[spec].(*types.PkgName)
+		if spec.Name != nil {
+			name = spec.Name.Name
+			if name == "_" || name == "." {
+				continue
+			}
+			pkgName, ok = p.info.Defs[spec.Name].(*types.PkgName)
+		}
+		if ok && !used[pkgName] {
+			astutil.DeleteNamedImport(p.prog.fset, f, name, importPath)
+		}
+	}
+}
+
+// ComputeImports returns the import names keyed by the paths of the packages
+// referenced by the types of the nodes, such as those of the generated code.
+// A name is the package name, or, if it is taken by a package of a lower path,
+// the package name followed by the smallest free number, such as template2.
+// NOTE: Return error, if the type of a node is nil.
+func ComputeImports(nodes ...TypeNode) (map[string]string, error) {
+	pkgs := make(map[string]*types.Package)
+	for i, node := range nodes {
+		if node.Type == nil {
+			return nil, fmt.Errorf("aster: ComputeImports of node %d without type", i)
+		}
+		collectPackages(node.Type, pkgs)
+	}
+	paths := make([]string, 0, len(pkgs))
+	for p := range pkgs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	imports := make(map[string]string, len(paths))
+	taken := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		name := pkgs[p].Name()
+		for i := 2; taken[name]; i++ {
+			name = pkgs[p].Name() + strconv.Itoa(i)
+		}
+		taken[name] = true
+		imports[p] = name
+	}
+	return imports, nil
+}
+
+// collectPackages collects the packages of the defined types and the aliases referenced by typ.
+func collectPackages(typ types.Type, pkgs map[string]*types.Package) {
+	addTypeArgs := func(args *types.TypeList) {
+		for i := 0; i < args.Len(); i++ {
+			collectPackages(args.At(i), pkgs)
+		}
+	}
+	switch t := typ.(type) {
+	case *types.Alias:
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			pkgs[pkg.Path()] = pkg
+		}
+		addTypeArgs(t.TypeArgs())
+	case *types.Named:
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			pkgs[pkg.Path()] = pkg
+		}
+		addTypeArgs(t.TypeArgs())
+	case *types.Pointer:
+		collectPackages(t.Elem(), pkgs)
+	case *types.Slice:
+		collectPackages(t.Elem(), pkgs)
+	case *types.Array:
+		collectPackages(t.Elem(), pkgs)
+	case *types.Chan:
+		collectPackages(t.Elem(), pkgs)
+	case *types.Map:
+		collectPackages(t.Key(), pkgs)
+		collectPackages(t.Elem(), pkgs)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			collectPackages(t.At(i).Type(), pkgs)
+		}
+	case *types.Signature:
+		collectPackages(t.Params(), pkgs)
+		collectPackages(t.Results(), pkgs)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			collectPackages(t.Field(i).Type(), pkgs)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			collectPackages(t.EmbeddedType(i), pkgs)
+		}
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			collectPackages(t.ExplicitMethod(i).Type(), pkgs)
+		}
+	}
+}
+
+// ImportAlias returns the explicit name of the import of path in the file,
+// such as x for `import x "encoding/xml"`.
+// NOTE: return false, if path is not imported or is imported without explicit name.
+func (f *File) ImportAlias(path string) (string, bool) {
+	for _, spec := range f.Imports {
+		if p, _ := strconv.Unquote(spec.Path.Value); p == path && spec.Name != nil {
+			return spec.Name.Name, true
+		}
+	}
+	return "", false
+}
+
+// RefTo returns the expression referring to the package-level object obj in the file,
+// such as x.Marshal for `import x "encoding/xml"`.
+// If the package of obj is not imported yet, the import is added,
+// named by an alias if the package name is already taken in the file.
+// NOTE: The packages are identified by their canonical paths, not by the local names.
+func (f *File) RefTo(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	if name := f.pkg.importQualifier(f.File)(obj.Pkg()); name != "" {
+		return name + "." + obj.Name()
+	}
+	return obj.Name()
+}
+
+// nameTakenInFile reports whether name is declared in the package scope
+// or by an import of file f.
+func (p *PackageInfo) nameTakenInFile(f *ast.File, name string) bool {
+	if p.Pkg.Scope().Lookup(name) != nil {
+		return true
+	}
+	for _, spec := range f.Imports {
+		if spec.Name != nil {
+			if spec.Name.Name == name {
+				return true
+			}
+			continue
+		}
+		if pkgName, ok := p.info.Implicits[spec].(*types.PkgName); ok {
+			if pkgName.Name() == name {
+				return true
+			}
+		} else if importPath, _ := strconv.Unquote(spec.Path.Value); path.Base(importPath) == name {
+			return true
+		}
+	}
+	return false
+}
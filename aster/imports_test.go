@@ -0,0 +1,257 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestRewriteImportPath(t *testing.T) {
+	var src = `package test
+import (
+	"encoding/json"
+	x "encoding/xml"
+	. "encoding/hex"
+	_ "encoding/base64"
+	"strings"
+)
+var _, _ = json.Marshal(nil)
+var _, _ = x.Marshal(nil)
+var _ = EncodeToString(nil)
+var _ = strings.TrimSpace("")
+`
+	prog, err := aster.LoadFile("../_out/rewrite_import.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := prog.RewriteImportPath("encoding", "example.com/encoding"); n != 4 {
+		t.Fatalf("RewriteImportPath: want 4 changes, got %d", n)
+	}
+	if n := prog.RewriteImportPath("example.com/encoding/json", "example.com/jsonx"); n != 1 {
+		t.Fatalf("RewriteImportPath: want 1 change, got %d", n)
+	}
+	codes, err := prog.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := codes["../_out/rewrite_import.go"]
+	t.Log(code)
+	for _, want := range []string{
+		`"example.com/jsonx"`,
+		`x "example.com/encoding/xml"`,
+		`. "example.com/encoding/hex"`,
+		`_ "example.com/encoding/base64"`,
+		`"strings"`,
+		`jsonx.Marshal(nil)`,
+		`x.Marshal(nil)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("RewriteImportPath: want %q in code", want)
+		}
+	}
+	// the major version suffix and the taken name are not the package name
+	src = `package test
+import (
+	"encoding/json"
+	"strings"
+)
+var jsonx = 1
+func f(s string) string {
+	var v2 = s
+	return strings.TrimSpace(v2)
+}
+var _, _ = json.Marshal(nil)
+`
+	prog, err = aster.LoadFile("../_out/rewrite_import_kept.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := prog.RewriteImportPath("strings", "example.com/strings/v2"); n != 1 {
+		t.Fatalf("RewriteImportPath: want 1 change, got %d", n)
+	}
+	if n := prog.RewriteImportPath("encoding/json", "example.com/jsonx"); n != 1 {
+		t.Fatalf("RewriteImportPath: want 1 change, got %d", n)
+	}
+	codes, err = prog.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = codes["../_out/rewrite_import_kept.go"]
+	t.Log(code)
+	for _, want := range []string{
+		`strings "example.com/strings/v2"`,
+		`json "example.com/jsonx"`,
+		`strings.TrimSpace(v2)`,
+		`json.Marshal(nil)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("RewriteImportPath: want %q in code", want)
+		}
+	}
+}
+
+func TestImportAlias(t *testing.T) {
+	var src = `package test
+import (
+	x "encoding/xml"
+	"strings"
+)
+var _, _ = x.Marshal(nil)
+var _ = strings.TrimSpace("")
+var strconv = 1
+`
+	prog, err := aster.LoadFile("../_out/import_alias.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := prog.InitialPackages()[0].Files()[0]
+	if name, ok := f.ImportAlias("encoding/xml"); !ok || name != "x" {
+		t.Fatalf("ImportAlias: want x, got %q", name)
+	}
+	if _, ok := f.ImportAlias("strings"); ok {
+		t.Fatal("ImportAlias: want no alias for strings")
+	}
+	marshal := prog.Package("encoding/xml").Pkg.Scope().Lookup("Marshal")
+	if ref := f.RefTo(marshal); ref != "x.Marshal" {
+		t.Fatalf("RefTo: want x.Marshal, got %s", ref)
+	}
+	itoa := prog.Package("strconv").Pkg.Scope().Lookup("Itoa")
+	if ref := f.RefTo(itoa); ref != "strconv2.Itoa" {
+		t.Fatalf("RefTo: want strconv2.Itoa, got %s", ref)
+	}
+	if name, ok := f.ImportAlias("strconv"); !ok || name != "strconv2" {
+		t.Fatalf("ImportAlias: want strconv2, got %q", name)
+	}
+}
+
+func TestUpgradeCalls(t *testing.T) {
+	var src = `package test
+import "io/ioutil"
+func OldSum(a, b int) int { return a + b }
+func NewSum(a, b int) int { return a + b }
+func Read() ([]byte, error) {
+	_ = OldSum(1, 2)
+	if b, err := ioutil.ReadFile("a.txt"); err != nil {
+		return b, err
+	}
+	return ioutil.ReadAll(nil)
+}
+`
+	prog, err := aster.LoadFile("../_out/upgrade_calls.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, newName := range []string{"example.com/newioutil.ReadAll", "io.NoSuchFunc", "ReadAll"} {
+		if _, err = prog.UpgradeCalls(map[string]string{"io/ioutil.ReadAll": newName}); err == nil {
+			t.Fatalf("UpgradeCalls(%s): want error", newName)
+		}
+	}
+	n, err := prog.UpgradeCalls(map[string]string{
+		"io/ioutil.ReadFile": "os.ReadFile",
+		"io/ioutil.ReadAll":  "io.ReadAll",
+		"test.OldSum":        "test.NewSum",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("UpgradeCalls: want 3 calls, got %d", n)
+	}
+	codes, err := prog.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := codes["../_out/upgrade_calls.go"]
+	t.Log(code)
+	for _, want := range []string{`"io"`, `"os"`, `os.ReadFile("a.txt")`, `io.ReadAll(nil)`, `_ = NewSum(1, 2)`} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("UpgradeCalls: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, "ioutil") {
+		t.Fatal("UpgradeCalls: want the unused import of io/ioutil removed")
+	}
+}
+
+func TestComputeImports(t *testing.T) {
+	var src = `package test
+import (
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+var A *template.Template
+var B map[string][]*htmltemplate.Template
+var C func(io.Reader) error
+var D int
+`
+	prog, err := aster.LoadFile("../_out/compute_imports.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []aster.TypeNode
+	for _, name := range []string{"A", "B", "C", "D"} {
+		nodes = append(nodes, aster.TypeNode{Type: prog.Lookup(aster.Var, 0, name)[0].Object().Type()})
+	}
+	imports, err := aster.ComputeImports(nodes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]string{"html/template": "template", "text/template": "template2", "io": "io"}
+	if !reflect.DeepEqual(imports, want) {
+		t.Fatalf("ComputeImports: want %v, got %v", want, imports)
+	}
+	if _, err = aster.ComputeImports(aster.TypeNode{}); err == nil {
+		t.Fatal("ComputeImports: want error for node without type")
+	}
+}
+
+func TestImporters(t *testing.T) {
+	var files = map[string]string{
+		"base/base.go": "package base\n\nconst Name = \"base\"\n",
+		"a/a.go":       "package a\n\nimport \"github.com/henrylee2cn/aster/_out/importers/base\"\n\nvar A = base.Name\n",
+		"b/b.go":       "package b\n\nimport (\n\t\"strings\"\n\n\t\"github.com/henrylee2cn/aster/_out/importers/base\"\n)\n\nvar B = strings.ToUpper(base.Name)\n",
+	}
+	dir := "../_out/importers"
+	for name, src := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog, err := aster.NewProgram().Import(dir+"/a", dir+"/b").Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range prog.Importers("github.com/henrylee2cn/aster/_out/importers/base") {
+		paths = append(paths, pkg.Pkg.Path())
+	}
+	if strings.Join(paths, ",") != dir+"/a,"+dir+"/b" {
+		t.Fatalf("Importers(base): want a and b, got %v", paths)
+	}
+	if list := prog.Importers("github.com/henrylee2cn/aster/_out/importers/a"); len(list) != 0 {
+		t.Fatalf("Importers(a): want none, got %v", list)
+	}
+}
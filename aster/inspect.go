@@ -15,15 +15,27 @@
 package aster
 
 import (
+	"context"
 	"go/ast"
+	"go/token"
 	"go/types"
 	"log"
+	"sort"
+	"strings"
 )
 
 func (p *PackageInfo) check() {
+	if p.checked {
+		return
+	}
+	p.checked = true
 	log.Printf("Checking package %s...", p.String())
+	cgoFiles := p.cgoGeneratedFiles()
 L:
 	for ident, obj := range p.info.Defs {
+		if len(cgoFiles) > 0 && strings.HasPrefix(ident.Name, "_") && cgoFiles[p.prog.fset.File(ident.Pos())] {
+			continue
+		}
 		switch GetObjKind(obj) {
 		case Bad, Lbl, Bui, Nil:
 			continue L
@@ -42,6 +54,26 @@ L:
 	}
 }
 
+// cgoGeneratedFiles returns the files of the package which are generated or rewritten by cgo,
+// or nil if the program keeps their declarations.
+func (p *PackageInfo) cgoGeneratedFiles() map[*token.File]bool {
+	if p.prog.keepCgoGenerated {
+		return nil
+	}
+	var files map[*token.File]bool
+	for _, f := range p.files {
+		if len(f.Comments) == 0 || !ast.IsGenerated(f) ||
+			!strings.Contains(f.Comments[0].Text(), "by cmd/cgo") {
+			continue
+		}
+		if files == nil {
+			files = make(map[*token.File]bool)
+		}
+		files[p.prog.fset.File(f.Pos())] = true
+	}
+	return files
+}
+
 // Inspect traverses created and imported packages in the program.
 func (prog *Program) Inspect(fn func(Facade) bool) {
 	for _, pkg := range prog.InitialPackages() {
@@ -53,6 +85,26 @@ func (prog *Program) Inspect(fn func(Facade) bool) {
 	}
 }
 
+// InspectChan streams the facades of created and imported packages in the program,
+// like Inspect, without collecting them into a slice.
+// The channel is closed when all facades are sent or ctx is done,
+// so cancel ctx to stop early and release the producer goroutine.
+func (prog *Program) InspectChan(ctx context.Context) <-chan Facade {
+	ch := make(chan Facade)
+	go func() {
+		defer close(ch)
+		prog.Inspect(func(fa Facade) bool {
+			select {
+			case ch <- fa:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return ch
+}
+
 // Lookup lookups facades in the program.
 //
 // Match any name if name="";
@@ -71,6 +123,35 @@ func (prog *Program) Lookup(objKindSet ObjKind, typKindSet TypKind, name string)
 	return
 }
 
+// LookupFuncs lookups the functions if methods is false, or the methods if methods is true,
+// in the program, like Lookup(Fun, Signature, name).
+// The methods include the interface methods.
+func (prog *Program) LookupFuncs(methods bool, name string) (list []Facade) {
+	for _, fa := range prog.Lookup(Fun, Signature, name) {
+		if fa.IsMethod() == methods {
+			list = append(list, fa)
+		}
+	}
+	return
+}
+
+// LookupAll lookups facades like Lookup, but in all the loaded packages,
+// including the transitively imported dependencies, in the order of package path.
+// NOTE: The facades of a dependency are collected on its first lookup.
+func (prog *Program) LookupAll(objKindSet ObjKind, typKindSet TypKind, name string) (list []Facade) {
+	pkgs := make([]*PackageInfo, 0, len(prog.allPackages))
+	for _, pkg := range prog.allPackages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Pkg.Path() < pkgs[j].Pkg.Path()
+	})
+	for _, pkg := range pkgs {
+		list = append(list, pkg.Lookup(objKindSet, typKindSet, name)...)
+	}
+	return
+}
+
 // FindFacade finds Facade by types.Type in the program.
 func (prog *Program) FindFacade(typ types.Type) (fa Facade, found bool) {
 	for _, pkg := range prog.allPackages {
@@ -82,8 +163,147 @@ func (prog *Program) FindFacade(typ types.Type) (fa Facade, found bool) {
 	return
 }
 
+// Invalidate clears the cached struct fields and tags of the package's facades,
+// which are recomputed from the AST on next access.
+// NOTE: Call it after editing the AST directly rather than with the aster setters.
+func (p *PackageInfo) Invalidate() {
+	for _, fa := range p.facades {
+		fa.structNode = nil
+		fa.structFields = nil
+	}
+}
+
+// Rebuild recollects the package's facades from the type-checker deductions
+// and the current AST, such as the doc comments.
+// NOTE: The facades got before are no longer traversed by Inspect and Lookup.
+func (p *PackageInfo) Rebuild() {
+	p.Invalidate()
+	p.facades = nil
+	p.checked = false
+	p.check()
+}
+
+// ResolveIdent finds the Facade which the identifier defines or refers to in the program,
+// including the unqualified identifiers imported by `import . "pkg"`.
+func (prog *Program) ResolveIdent(ident *ast.Ident) (fa Facade, found bool) {
+	pkg, _, _ := prog.pathEnclosingInterval(ident.Pos(), ident.End())
+	if pkg == nil {
+		return nil, false
+	}
+	obj := pkg.info.ObjectOf(ident)
+	if obj == nil {
+		return nil, false
+	}
+	return prog.facadeOf(obj)
+}
+
+// MutableGlobals returns the package-level variables of the initial packages,
+// except the function variables, which are written from more than one function of the initial packages,
+// including the writes to their fields and elements, such as `g.n++` and `g[k] = v`.
+// They are the candidates for data races.
+// NOTE: It is heuristic, the writes by pointers and function values are not detected.
+func (prog *Program) MutableGlobals() []Facade {
+	writers := make(map[*types.Var]map[*ast.FuncDecl]bool)
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					var lhs []ast.Expr
+					switch x := n.(type) {
+					case *ast.AssignStmt:
+						if x.Tok != token.DEFINE {
+							lhs = x.Lhs
+						}
+					case *ast.IncDecStmt:
+						lhs = []ast.Expr{x.X}
+					}
+					for _, expr := range lhs {
+						v := pkg.globalVarOf(expr)
+						if v == nil {
+							continue
+						}
+						if writers[v] == nil {
+							writers[v] = make(map[*ast.FuncDecl]bool)
+						}
+						writers[v][fn] = true
+					}
+					return true
+				})
+			}
+		}
+	}
+	var list []Facade
+	for _, pkg := range prog.InitialPackages() {
+		for _, fa := range pkg.facades {
+			if v, ok := fa.obj.(*types.Var); ok && len(writers[v]) > 1 && GetTypKind(v.Type()) != Signature {
+				list = append(list, fa)
+			}
+		}
+	}
+	return list
+}
+
+// globalVarOf returns the package-level variable which is the root of the
+// assigned expression, such as g of `g.a[i]`, or nil.
+func (p *PackageInfo) globalVarOf(expr ast.Expr) *types.Var {
+	id := p.rootIdent(expr)
+	if id == nil {
+		return nil
+	}
+	v, ok := p.info.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() {
+		return nil
+	}
+	return v
+}
+
+// rootIdent returns the identifier which is the root of the selector, index and
+// dereference expression, such as g of `g.a[i]` or Var of `pkg.Var`, or nil.
+func (p *PackageInfo) rootIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch x := expr.(type) {
+		case *ast.ParenExpr:
+			expr = x.X
+		case *ast.IndexExpr:
+			expr = x.X
+		case *ast.StarExpr:
+			expr = x.X
+		case *ast.SelectorExpr:
+			if id, ok := x.X.(*ast.Ident); ok {
+				if _, ok := p.info.Uses[id].(*types.PkgName); ok {
+					return x.Sel // qualified identifier, such as pkg.Var
+				}
+			}
+			expr = x.X
+		case *ast.Ident:
+			return x
+		default:
+			return nil
+		}
+	}
+}
+
+// facadeOf finds the facade of the object in its declaring package.
+func (prog *Program) facadeOf(obj types.Object) (*facade, bool) {
+	if obj.Pkg() == nil {
+		return nil, false
+	}
+	declPkg, ok := prog.allPackages[obj.Pkg()]
+	if !ok {
+		return nil, false
+	}
+	declPkg.check() // make sure the facades of dependencies are collected
+	facade, idx := declPkg.getFacadeByObj(obj)
+	return facade, idx != -1
+}
+
 // Inspect traverses facades in the package.
 func (p *PackageInfo) Inspect(fn func(Facade) bool) {
+	p.check() // make sure the facades of dependencies are collected
 	for _, fa := range p.facades {
 		if !fn(fa) {
 			return
@@ -109,8 +329,21 @@ func (p *PackageInfo) Lookup(objKindSet ObjKind, typKindSet TypKind, name string
 	return
 }
 
+// LookupFuncs lookups the functions if methods is false, or the methods if methods is true,
+// in the package, like Lookup(Fun, Signature, name).
+// The methods include the interface methods.
+func (p *PackageInfo) LookupFuncs(methods bool, name string) (list []Facade) {
+	for _, fa := range p.Lookup(Fun, Signature, name) {
+		if fa.IsMethod() == methods {
+			list = append(list, fa)
+		}
+	}
+	return
+}
+
 // FindFacade finds Facade by types.Type in the package.
 func (p *PackageInfo) FindFacade(typ types.Type) (fa Facade, found bool) {
+	p.check() // make sure the facades of dependencies are collected
 	facade, idx := p.getFacadeByTyp(typ)
 	return facade, idx != -1
 }
@@ -151,6 +384,13 @@ func (p *PackageInfo) addFacade(ident *ast.Ident, obj types.Object) {
 	})
 }
 
+// RemoveFacade removes fa from the package's facades,
+// so that it is no longer traversed by Inspect and Lookup.
+// NOTE: It does not modify the AST.
+func (p *PackageInfo) RemoveFacade(fa Facade) {
+	p.removeFacade(fa.Ident())
+}
+
 func (p *PackageInfo) removeFacade(ident *ast.Ident) {
 	_, idx := p.getFacade(ident)
 	if idx >= 0 {
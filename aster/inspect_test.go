@@ -15,7 +15,15 @@
 package aster_test
 
 import (
+	"context"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/henrylee2cn/aster/aster"
 )
@@ -59,3 +67,288 @@ func TestLookup(t *testing.T) {
 		t.Log(fa)
 	}
 }
+
+func TestLookupAll(t *testing.T) {
+	var src = `package test
+import "strings"
+type Builder struct{ b strings.Builder }
+`
+	prog, err := aster.LoadFile("../_out/lookup_all.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := prog.Lookup(aster.Typ, aster.Struct, "Builder"); len(list) != 1 {
+		t.Fatalf("Lookup: want 1 in the initial packages, got %d", len(list))
+	}
+	list := prog.LookupAll(aster.Typ, aster.Struct, "Builder")
+	var paths []string
+	for _, fa := range list {
+		paths = append(paths, fa.Object().Pkg().Path())
+	}
+	if strings.Join(paths, ",") != "strings,test" {
+		t.Fatalf("LookupAll: want strings,test, got %v", paths)
+	}
+}
+
+func TestLookupFuncs(t *testing.T) {
+	var src = `package test
+type T struct{}
+func (T) Get() int { return 0 }
+func (*T) Set(int) {}
+func New() *T { return nil }
+type Getter interface{ Get() int }
+var F = func() {}
+`
+	prog, err := aster.LoadFile("../_out/lookup_funcs.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(list []aster.Facade) string {
+		aster.SortFacades(list, aster.SortByPosition)
+		var s []string
+		for _, fa := range list {
+			if fa.IsMethod() != (fa.Name() != "New") {
+				t.Fatalf("IsMethod(%s): got %v", fa.Name(), fa.IsMethod())
+			}
+			s = append(s, fa.Name())
+		}
+		return strings.Join(s, ",")
+	}
+	if got := names(prog.LookupFuncs(false, "")); got != "New" {
+		t.Fatalf("LookupFuncs(false): want New, got %s", got)
+	}
+	if got := names(prog.LookupFuncs(true, "")); got != "Get,Set,Get" {
+		t.Fatalf("LookupFuncs(true): want Get,Set,Get, got %s", got)
+	}
+	if got := names(prog.Package("test").LookupFuncs(true, "Set")); got != "Set" {
+		t.Fatalf("LookupFuncs(true, Set): want Set, got %s", got)
+	}
+}
+
+func TestInspectChan(t *testing.T) {
+	var src = `package test
+type A int
+type B int
+type C int
+type D int
+`
+	prog, err := aster.LoadFile("../_out/inspect_chan.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := prog.InspectChan(ctx)
+	for i := 0; i < 2; i++ {
+		if fa, ok := <-ch; !ok || fa == nil {
+			t.Fatalf("InspectChan: want facade #%d", i)
+		}
+	}
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("InspectChan: the channel is not closed after cancellation")
+		}
+	}
+}
+
+func TestMutableGlobals(t *testing.T) {
+	var src = `package test
+type stats struct{ n int }
+var (
+	counter  int
+	cache    = map[string]int{}
+	st       stats
+	readOnly = 1
+	once     int
+	hook     func()
+)
+func inc() { counter++; cache["a"] = 1; st.n++; once = 2; hook = nil }
+func reset() {
+	counter = 0
+	func() { cache["b"] = readOnly }()
+	st = stats{}
+	hook = inc
+	local := readOnly
+	_ = local
+}
+`
+	prog, err := aster.LoadFile("../_out/mutable_globals.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := prog.MutableGlobals()
+	aster.SortFacades(list)
+	var names []string
+	for _, fa := range list {
+		names = append(names, fa.Name())
+	}
+	if strings.Join(names, ",") != "cache,counter,st" {
+		t.Fatalf("MutableGlobals: want cache,counter,st, got %v", names)
+	}
+}
+
+func TestRemoveFacade(t *testing.T) {
+	var src = `package test
+type A int
+type B string
+`
+	prog, err := aster.LoadFile("../_out/remove_facade.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	list := pkg.Lookup(aster.Typ, 0, "A")
+	if len(list) != 1 {
+		t.Fatalf("Lookup A: want 1, got %d", len(list))
+	}
+	pkg.RemoveFacade(list[0])
+	if list = pkg.Lookup(aster.Typ, 0, "A"); len(list) != 0 {
+		t.Fatalf("Lookup A after RemoveFacade: want 0, got %d", len(list))
+	}
+	if list = prog.Lookup(aster.Typ, 0, "B"); len(list) != 1 {
+		t.Fatalf("Lookup B after RemoveFacade: want 1, got %d", len(list))
+	}
+}
+
+func TestResolveIdent(t *testing.T) {
+	var src = `package test
+import (
+	. "strings"
+	"unicode"
+)
+var a = TrimSpace(" a ")
+var b = unicode.IsSpace(' ')
+var c = a
+`
+	prog, err := aster.LoadFile("../_out/resolve_ident.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]string{
+		"TrimSpace": "strings",
+		"IsSpace":   "unicode",
+		"a":         "test",
+	}
+	var count int
+	for _, f := range prog.Package("test").Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			vs, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			ast.Inspect(vs.Values[0], func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok || want[ident.Name] == "" {
+					return true
+				}
+				fa, found := prog.ResolveIdent(ident)
+				if !found {
+					t.Fatalf("ResolveIdent: %s not found", ident.Name)
+				}
+				if fa.Name() != ident.Name || fa.Object().Pkg().Path() != want[ident.Name] {
+					t.Fatalf("ResolveIdent: %s want %s.%s, got %v", ident.Name, want[ident.Name], ident.Name, fa.Object())
+				}
+				count++
+				return true
+			})
+			return false
+		})
+	}
+	if count != len(want) {
+		t.Fatalf("ResolveIdent: want %d references, got %d", len(want), count)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	var src = `package test
+// S old doc
+type S struct {
+	A int
+}
+`
+	prog, err := aster.LoadFile("../_out/rebuild.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	s := pkg.Lookup(aster.Typ, aster.Struct, "S")[0]
+	a, _ := s.FieldByName("A")
+	if a.Tags().String() != "" {
+		t.Fatalf("Tags: want empty, got %q", a.Tags())
+	}
+	// edit the AST directly
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.GenDecl:
+				n.Doc.List[0].Text = "// S new doc"
+			case *ast.Field:
+				n.Tag = &ast.BasicLit{ValuePos: n.Type.End() + 1, Kind: token.STRING, Value: "`json:\"a\"`"}
+			}
+			return true
+		})
+	}
+	pkg.Invalidate()
+	a, _ = s.FieldByName("A")
+	if a.Tags().String() != `json:"a"` {
+		t.Fatalf("Tags after Invalidate: want %q, got %q", `json:"a"`, a.Tags())
+	}
+	n := len(pkg.Lookup(0, 0, ""))
+	pkg.Rebuild()
+	if m := len(pkg.Lookup(0, 0, "")); m != n {
+		t.Fatalf("Rebuild: want %d facades, got %d", n, m)
+	}
+	s = pkg.Lookup(aster.Typ, aster.Struct, "S")[0]
+	if s.Doc() != "S new doc\n" {
+		t.Fatalf("Doc after Rebuild: want %q, got %q", "S new doc\n", s.Doc())
+	}
+}
+
+func TestSkipCgoGenerated(t *testing.T) {
+	if !build.Default.CgoEnabled {
+		t.Skip("cgo is disabled")
+	}
+	var src = `package cgopkg
+
+// #include <stdlib.h>
+import "C"
+
+// Rand returns a random number.
+func Rand() int { return int(C.rand()) }
+
+// Size is the size of C.
+type Size C.size_t
+`
+	dir := "../_out/cgo_pkg"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgo.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for _, skip := range []bool{true, false} {
+		prog, err := aster.NewProgram().SetSkipCgoGenerated(skip).Import(dir).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		var leaked bool
+		prog.Inspect(func(fa aster.Facade) bool {
+			names = append(names, fa.Name())
+			leaked = leaked || strings.HasPrefix(fa.Name(), "_")
+			return true
+		})
+		if leaked == skip {
+			t.Fatalf("SetSkipCgoGenerated(%v): got the facades %v", skip, names)
+		}
+		if len(prog.Lookup(aster.Fun, 0, "Rand")) != 1 || len(prog.Lookup(aster.Typ, 0, "Size")) != 1 {
+			t.Fatalf("SetSkipCgoGenerated(%v): want Rand and Size, got %v", skip, names)
+		}
+	}
+}
@@ -16,13 +16,20 @@ package aster
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"strings"
 )
 
 // ---------------------------------- TypKind = Interface ----------------------------------
 
 // NOTE: Panic, if TypKind != Interface
 func (fa *facade) iface() *types.Interface {
+	if fa.ifaceType != nil {
+		return fa.ifaceType
+	}
 	typ := fa.typ()
 	t, ok := typ.(*types.Interface)
 	if !ok {
@@ -67,3 +74,277 @@ func (fa *facade) IfaceNumEmbeddeds() int {
 func (fa *facade) IfaceNumExplicitMethods() int {
 	return fa.iface().NumExplicitMethods()
 }
+
+// IfaceMethodDoc returns the lead comment of the interface method by name,
+// including the methods of embedded interfaces.
+// NOTE: Panic, if TypKind != Interface
+func (fa *facade) IfaceMethodDoc(name string) string {
+	t := fa.iface()
+	for i := 0; i < t.NumMethods(); i++ {
+		if fn := t.Method(i); fn.Name() == name {
+			if method, ok := fa.pkg.prog.facadeOf(fn); ok {
+				return method.Doc()
+			}
+		}
+	}
+	return ""
+}
+
+// IfaceAddMethod adds the method to the defined interface type,
+// the signature is like `(key string) (string, error)`, with or without the func keyword.
+// The Iface* methods of the facade reflect the edit, while the type-checked object type is unchanged.
+// NOTE: Return error, if TypKind != Interface, or it is not a defined type,
+// or the signature is invalid, or the method already exists.
+func (fa *facade) IfaceAddMethod(name, signature string) error {
+	if _, ok := fa.typ().(*types.Interface); !ok || fa.ObjKind() != Typ || fa.IsAlias() || fa.typKind() != named {
+		return fmt.Errorf("aster: %s is not a defined interface type", fa.Name())
+	}
+	t := fa.iface()
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("aster: invalid method name %q", name)
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(t, false, fa.pkg.Pkg, name); obj != nil {
+		return fmt.Errorf("aster: method %s.%s already exists", fa.Name(), name)
+	}
+	node := fa.ifaceNode()
+	if node == nil {
+		return fmt.Errorf("aster: can't find the declaration of interface %s", fa.Name())
+	}
+	signature = "func" + strings.TrimPrefix(strings.TrimSpace(signature), "func")
+	expr, err := parser.ParseExpr(signature)
+	if err != nil {
+		return err
+	}
+	funcType, ok := expr.(*ast.FuncType)
+	if !ok {
+		return fmt.Errorf("aster: invalid method signature %q", signature)
+	}
+	tv, err := types.Eval(fa.pkg.prog.fset, fa.pkg.Pkg, fa.ident.Pos(), signature)
+	if err != nil {
+		return err
+	}
+	sig := tv.Type.(*types.Signature)
+
+	pos := node.Methods.Closing
+	resetPos(funcType, pos)
+	ident := &ast.Ident{NamePos: pos, Name: name}
+	node.Methods.List = append(node.Methods.List, &ast.Field{
+		Names: []*ast.Ident{ident},
+		Type:  funcType,
+	})
+	recv := types.NewVar(pos, fa.pkg.Pkg, "", fa.obj.Type())
+	fn := types.NewFunc(pos, fa.pkg.Pkg, name,
+		types.NewSignatureType(recv, nil, nil, sig.Params(), sig.Results(), sig.Variadic()))
+	fa.pkg.info.Defs[ident] = fn
+	fa.pkg.facades = append(fa.pkg.facades, &facade{obj: fn, pkg: fa.pkg, ident: ident})
+
+	fa.setIface(append(ifaceExplicitMethods(t, ""), fn), ifaceEmbeddeds(t))
+	return nil
+}
+
+// IfaceRemoveMethod removes the explicit method from the defined interface type by name,
+// and reports whether it was found.
+// The embedded interfaces are removed only by their type expression, such as `io.Reader`.
+// NOTE: Panic, if TypKind != Interface
+func (fa *facade) IfaceRemoveMethod(name string) bool {
+	t := fa.iface()
+	if fa.ObjKind() != Typ || fa.IsAlias() || fa.typKind() != named {
+		return false
+	}
+	node := fa.ifaceNode()
+	if node == nil {
+		return false
+	}
+	for i, field := range node.Methods.List {
+		if len(field.Names) == 0 {
+			typ := fa.pkg.info.TypeOf(field.Type)
+			if s, err := fa.pkg.FormatNode(field.Type); err != nil || s != name || typ == nil {
+				continue
+			}
+			embeddeds := ifaceEmbeddeds(t)
+			for j, e := range embeddeds {
+				if types.Identical(e, typ) {
+					embeddeds = append(embeddeds[:j:j], embeddeds[j+1:]...)
+					break
+				}
+			}
+			fa.removeIfaceField(node, i)
+			fa.setIface(ifaceExplicitMethods(t, ""), embeddeds)
+			return true
+		}
+		if field.Names[0].Name != name {
+			continue
+		}
+		fa.removeIfaceField(node, i)
+		fa.pkg.removeFacade(field.Names[0])
+		fa.setIface(ifaceExplicitMethods(t, name), ifaceEmbeddeds(t))
+		return true
+	}
+	return false
+}
+
+// ifaceNode returns the AST node of the interface type.
+func (fa *facade) ifaceNode() *ast.InterfaceType {
+	t := fa.typ()
+	for expr, tv := range fa.pkg.info.Types {
+		if n, ok := expr.(*ast.InterfaceType); ok && tv.Type == t {
+			return n
+		}
+	}
+	return nil
+}
+
+func (fa *facade) removeIfaceField(node *ast.InterfaceType, i int) {
+	field := node.Methods.List[i]
+	node.Methods.List = append(node.Methods.List[:i:i], node.Methods.List[i+1:]...)
+	fa.pkg.removeComments(field.Doc, field.Comment)
+}
+
+// setIface replaces the interface type of the facade with a new one of the methods and embeddeds,
+// leaving the type-checked defined type unchanged.
+func (fa *facade) setIface(methods []*types.Func, embeddeds []types.Type) {
+	fa.ifaceType = types.NewInterfaceType(methods, embeddeds).Complete()
+}
+
+func ifaceExplicitMethods(t *types.Interface, except string) []*types.Func {
+	methods := make([]*types.Func, 0, t.NumExplicitMethods())
+	for i := 0; i < t.NumExplicitMethods(); i++ {
+		if fn := t.ExplicitMethod(i); fn.Name() != except {
+			methods = append(methods, fn)
+		}
+	}
+	return methods
+}
+
+func ifaceEmbeddeds(t *types.Interface) []types.Type {
+	embeddeds := make([]types.Type, t.NumEmbeddeds())
+	for i := range embeddeds {
+		embeddeds[i] = t.EmbeddedType(i)
+	}
+	return embeddeds
+}
+
+// Implementers returns the defined non-interface types in the initial packages,
+// which implement iface with their value or pointer method sets.
+// The interface may be declared in any package of the program, such as io.Reader.
+// NOTE: Panic, if iface TypKind != Interface
+func (prog *Program) Implementers(iface Facade) []Facade {
+	return prog.implementers(iface.(*facade).iface())
+}
+
+func (prog *Program) implementers(t *types.Interface) []Facade {
+	var list []Facade
+	prog.Inspect(func(fa Facade) bool {
+		if fa.ObjKind() != Typ || fa.IsAlias() || fa.TypKind() == Interface {
+			return true
+		}
+		if types.Implements(types.NewPointer(fa.Object().Type()), t) {
+			list = append(list, fa)
+		}
+		return true
+	})
+	return list
+}
+
+// SingleImplInterfaces returns the unexported interfaces of the initial packages,
+// which have exactly one implementer in the program, sorted by package and position.
+// They are candidates for removal in favor of the implementing type.
+// NOTE:
+//  The exported interfaces are excluded, since they may be implemented by other programs;
+//  The empty, generic and constraint interfaces are excluded.
+func (prog *Program) SingleImplInterfaces() []Facade {
+	var list []Facade
+	for _, fa := range prog.Lookup(Typ, Interface, "") {
+		if fa.Exported() || fa.IsAlias() {
+			continue
+		}
+		if named, ok := fa.Object().Type().(*types.Named); !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		t := fa.(*facade).iface()
+		if t.NumMethods() == 0 || !t.IsMethodSet() {
+			continue
+		}
+		if len(prog.implementers(t)) == 1 {
+			list = append(list, fa)
+		}
+	}
+	SortFacades(list, SortByPackage, SortByPosition)
+	return list
+}
+
+// MethodMismatch describes a method of an interface, which a type does not implement.
+type MethodMismatch struct {
+	Name string
+	// Want is the signature of the interface method.
+	Want *types.Signature
+	// Have is the signature of the method with the same name, or nil if it is missing.
+	Have *types.Signature
+	// PointerReceiver reports whether the method is only in the method set of the pointer type.
+	PointerReceiver bool
+}
+
+// SatisfactionGap returns the methods of iface, which typ lacks or declares with wrong signatures,
+// sorted by name, such as for the idiom `var _ I = (*T)(nil)`.
+// It returns nil if typ implements iface.
+// NOTE: Panic, if iface TypKind != Interface
+func (prog *Program) SatisfactionGap(typ types.Type, iface Facade) []MethodMismatch {
+	t := iface.(*facade).iface()
+	var list []MethodMismatch
+	for i := 0; i < t.NumMethods(); i++ {
+		m := t.Method(i)
+		want := m.Type().(*types.Signature)
+		mismatch := MethodMismatch{Name: m.Name(), Want: want}
+		obj, _, _ := types.LookupFieldOrMethod(typ, false, m.Pkg(), m.Name())
+		if obj == nil {
+			obj, _, _ = types.LookupFieldOrMethod(typ, true, m.Pkg(), m.Name())
+			mismatch.PointerReceiver = obj != nil
+		}
+		if fn, ok := obj.(*types.Func); ok {
+			mismatch.Have = fn.Type().(*types.Signature)
+			if !mismatch.PointerReceiver && types.Identical(mismatch.Have, want) {
+				continue
+			}
+		} else {
+			mismatch.PointerReceiver = false
+		}
+		list = append(list, mismatch)
+	}
+	return list
+}
+
+// DeclaredSatisfies returns the interfaces that fa is explicitly asserted to satisfy
+// by the idiom `var _ I = (*T)(nil)` or `var _ I = T{}` in the initial packages.
+func (prog *Program) DeclaredSatisfies(fa Facade) []types.Type {
+	var list []types.Type
+	typ := fa.Object().Type()
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			for _, decl := range f.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					if vs.Type == nil || len(vs.Names) != len(vs.Values) {
+						continue
+					}
+					for i, name := range vs.Names {
+						if name.Name != "_" {
+							continue
+						}
+						t := pkg.info.TypeOf(vs.Values[i])
+						if ptr, ok := t.(*types.Pointer); ok {
+							t = ptr.Elem()
+						}
+						if t != nil && types.Identical(t, typ) {
+							list = append(list, pkg.info.TypeOf(vs.Type))
+						}
+					}
+				}
+			}
+		}
+	}
+	return list
+}
@@ -15,6 +15,8 @@
 package aster_test
 
 import (
+	"go/types"
+	"strings"
 	"testing"
 
 	"github.com/henrylee2cn/aster/aster"
@@ -49,3 +51,276 @@ func(m *M)String()string{return "M"}
 		t.Fatalf("type M implements I2 interface")
 	}
 }
+
+func TestDeclaredSatisfies(t *testing.T) {
+	var src = `package test
+import (
+	"fmt"
+	"io"
+)
+type M struct{}
+func(m *M)Write(p []byte)(int, error){return len(p), nil}
+func(m M)String()string{return "M"}
+var (
+	_ io.Writer = (*M)(nil)
+	_ fmt.Stringer = M{}
+	_ = M{}
+)
+`
+	prog, err := aster.LoadFile("../_out/declared_satisfies.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := prog.Lookup(aster.Typ, aster.Struct, "M")[0]
+	list := prog.DeclaredSatisfies(m)
+	if len(list) != 2 || list[0].String() != "io.Writer" || list[1].String() != "fmt.Stringer" {
+		t.Fatalf("DeclaredSatisfies: want [io.Writer fmt.Stringer], got %v", list)
+	}
+}
+
+func TestIfaceMethodDoc(t *testing.T) {
+	var src = `package test
+type Closer interface {
+	// Close releases the resources.
+	Close() error
+}
+type I interface {
+	// Get returns the value by key.
+	// It returns "" if not found.
+	Get(key string) string
+	Set(key, value string) // no lead comment
+	Closer
+}
+`
+	prog, err := aster.LoadFile("../_out/iface_method_doc.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := prog.Lookup(aster.Typ, aster.Interface, "I")[0]
+	var getDoc = "Get returns the value by key.\nIt returns \"\" if not found.\n"
+	if doc := i.IfaceMethodDoc("Get"); doc != getDoc {
+		t.Fatalf("IfaceMethodDoc(Get): want %q, got %q", getDoc, doc)
+	}
+	if doc := i.IfaceMethodDoc("Set"); doc != "" {
+		t.Fatalf("IfaceMethodDoc(Set): want empty, got %q", doc)
+	}
+	if doc := i.IfaceMethodDoc("Close"); doc != "Close releases the resources.\n" {
+		t.Fatalf("IfaceMethodDoc(Close): want the doc of Closer.Close, got %q", doc)
+	}
+	for j := 0; j < i.IfaceNumExplicitMethods(); j++ {
+		if m := i.IfaceExplicitMethod(j); m.Name() == "Get" && m.Doc() != getDoc {
+			t.Fatalf("IfaceExplicitMethod(Get).Doc(): want %q, got %q", getDoc, m.Doc())
+		}
+	}
+}
+
+func TestIfaceAddRemoveMethod(t *testing.T) {
+	var src = `package test
+import "fmt"
+type I interface {
+	// Get returns the value by key.
+	Get(key string) string
+	fmt.Stringer
+}
+`
+	prog, err := aster.LoadFile("../_out/iface_add_method.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := prog.Lookup(aster.Typ, aster.Interface, "I")[0]
+	if err = i.IfaceAddMethod("Set", "(key, value string) error"); err != nil {
+		t.Fatal(err)
+	}
+	if err = i.IfaceAddMethod("Get", "func() string"); err == nil {
+		t.Fatal("IfaceAddMethod: want error for an existing method")
+	}
+	if err = i.IfaceAddMethod("Bad", "(x Undefined)"); err == nil {
+		t.Fatal("IfaceAddMethod: want error for an undefined type")
+	}
+	if n := i.IfaceNumExplicitMethods(); n != 2 {
+		t.Fatalf("IfaceNumExplicitMethods: want 2, got %d", n)
+	}
+	var names []string
+	for j := 0; j < i.IfaceNumExplicitMethods(); j++ {
+		names = append(names, i.IfaceExplicitMethod(j).Name())
+	}
+	if strings.Join(names, ",") != "Get,Set" {
+		t.Fatalf("IfaceExplicitMethod: want Get,Set, got %v", names)
+	}
+	if n := prog.Lookup(aster.Typ, aster.Interface, "I")[0].IfaceNumExplicitMethods(); n != 2 {
+		t.Fatalf("Lookup(I).IfaceNumExplicitMethods: want 2, got %d", n)
+	}
+	if n := i.Object().Type().Underlying().(*types.Interface).NumExplicitMethods(); n != 1 {
+		t.Fatalf("IfaceAddMethod: want the type-checked interface unchanged, got %d methods", n)
+	}
+	pkg := prog.Package("test")
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `type I interface {
+	// Get returns the value by key.
+	Get(key string) string
+	fmt.Stringer
+	Set(key, value string) error
+}`
+	if !strings.Contains(code, want) {
+		t.Fatalf("IfaceAddMethod: want:\n%s\ngot:\n%s", want, code)
+	}
+
+	if i.IfaceRemoveMethod("String") {
+		t.Fatal("IfaceRemoveMethod(String): want false for a method of embedded interface")
+	}
+	if !i.IfaceRemoveMethod("Get") || !i.IfaceRemoveMethod("fmt.Stringer") {
+		t.Fatal("IfaceRemoveMethod: want true")
+	}
+	if i.IfaceNumExplicitMethods() != 1 || i.IfaceNumEmbeddeds() != 0 {
+		t.Fatalf("IfaceRemoveMethod: want 1 method and 0 embeddeds, got %d and %d",
+			i.IfaceNumExplicitMethods(), i.IfaceNumEmbeddeds())
+	}
+	code, err = pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "type I interface {\n\tSet(key, value string) error\n}"
+	if !strings.Contains(code, want) || strings.Contains(code, "Get returns") {
+		t.Fatalf("IfaceRemoveMethod: want:\n%s\ngot:\n%s", want, code)
+	}
+}
+
+func TestImplementers(t *testing.T) {
+	var src = `package test
+import "io"
+type File struct{}
+func (f *File) Read(p []byte) (int, error) { return 0, nil }
+type Bytes []byte
+func (b Bytes) Read(p []byte) (int, error) { return 0, nil }
+type Writer struct{}
+func (w Writer) Write(p []byte) (int, error) { return 0, nil }
+type ReadCloser interface {
+	io.Reader
+	Close() error
+}
+`
+	prog, err := aster.LoadFile("../_out/implementers.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := prog.Package("io").Lookup(aster.Typ, aster.Interface, "Reader")
+	if len(reader) != 1 {
+		t.Fatalf("Lookup(io.Reader): want 1, got %d", len(reader))
+	}
+	list := prog.Implementers(reader[0])
+	aster.SortFacades(list)
+	var names []string
+	for _, fa := range list {
+		names = append(names, fa.Name())
+	}
+	if strings.Join(names, ",") != "Bytes,File" {
+		t.Fatalf("Implementers(io.Reader): want Bytes,File, got %v", names)
+	}
+	file := prog.Lookup(aster.Typ, aster.Struct, "File")[0]
+	if !file.Implements(reader[0], true) || file.Implements(reader[0], false) {
+		t.Fatal("Implements(io.Reader): want true only for *File")
+	}
+}
+
+func TestImplementsSignatures(t *testing.T) {
+	var src = `package test
+type T struct{}
+func (T) Get(key string, n int) (string, error) { return "", nil }
+type Same interface{ Get(key string, n int) (string, error) }
+type LastParam interface{ Get(key string, n int64) (string, error) }
+type LastResult interface{ Get(key string, n int) (string, bool) }
+type Empty interface{}
+`
+	prog, err := aster.LoadFile("../_out/implements_signatures.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := prog.Lookup(aster.Typ, aster.Struct, "T")[0]
+	for name, want := range map[string]bool{
+		"Same":       true,
+		"LastParam":  false,
+		"LastResult": false,
+		"Empty":      true,
+	} {
+		iface := prog.Lookup(aster.Typ, aster.Interface, name)[0]
+		for _, usePtr := range []bool{false, true} {
+			if got := typ.Implements(iface, usePtr); got != want {
+				t.Fatalf("Implements(%s, %v): want %v, got %v", name, usePtr, want, got)
+			}
+		}
+	}
+}
+
+func TestSatisfactionGap(t *testing.T) {
+	var src = `package test
+type Store interface {
+	Get(k string) string
+	Set(k, v string)
+	Delete(k string) error
+	Len() int
+}
+type Map struct{ Len bool }
+func (m Map) Get(k string) string { return "" }
+func (m *Map) Set(k, v string) {}
+func (m Map) Delete(k string) {}
+`
+	prog, err := aster.LoadFile("../_out/satisfaction_gap.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := prog.Lookup(aster.Typ, aster.Interface, "Store")[0]
+	typ := prog.Lookup(aster.Typ, aster.Struct, "Map")[0].Object().Type()
+	var report = func(list []aster.MethodMismatch) string {
+		var a []string
+		for _, m := range list {
+			s := m.Name
+			if m.Have == nil {
+				s += ":missing"
+			} else if m.PointerReceiver {
+				s += ":pointer"
+			} else {
+				s += ":" + m.Have.String()
+			}
+			a = append(a, s)
+		}
+		return strings.Join(a, ",")
+	}
+	if got, want := report(prog.SatisfactionGap(typ, store)), "Delete:func(k string),Len:missing,Set:pointer"; got != want {
+		t.Fatalf("SatisfactionGap(Map): want %s, got %s", want, got)
+	}
+	if got, want := report(prog.SatisfactionGap(types.NewPointer(typ), store)), "Delete:func(k string),Len:missing"; got != want {
+		t.Fatalf("SatisfactionGap(*Map): want %s, got %s", want, got)
+	}
+	if want := prog.SatisfactionGap(typ, store)[0].Want; want.String() != "func(k string) error" {
+		t.Fatalf("SatisfactionGap: want signature func(k string) error, got %s", want)
+	}
+}
+
+func TestSingleImplInterfaces(t *testing.T) {
+	var src = `package test
+type store interface{ Get(key string) string }
+type memStore map[string]string
+func (m memStore) Get(key string) string { return m[key] }
+type closer interface{ Close() error }
+type file struct{}
+func (*file) Close() error { return nil }
+type conn struct{}
+func (conn) Close() error { return nil }
+type Getter interface{ Get(key string) string }
+type empty interface{}
+`
+	prog, err := aster.LoadFile("../_out/single_impl.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fa := range prog.SingleImplInterfaces() {
+		names = append(names, fa.Name())
+	}
+	if strings.Join(names, ",") != "store" {
+		t.Fatalf("SingleImplInterfaces: want store, got %v", names)
+	}
+}
@@ -96,8 +96,9 @@ func GetObjKind(obj types.Object) ObjKind {
 }
 
 // GetTypKind returns what the types.Type represents.
+// NOTE: An alias type represents what its actual type represents.
 func GetTypKind(typ types.Type) TypKind {
-	switch typ.(type) {
+	switch types.Unalias(typ).(type) {
 	case *types.Basic:
 		return Basic
 	case *types.Array:
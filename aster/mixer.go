@@ -117,3 +117,16 @@ func (fa *facade) BasicInfo() types.BasicInfo {
 func (fa *facade) BasicKind() types.BasicKind {
 	return fa.basic().Kind()
 }
+
+// Bits returns the bit width of the numeric basic type.
+// The width of int, uint and uintptr depends on the sizes of the target platform.
+// NOTE:
+//  Panic, if TypKind != Basic;
+//  Return false, if it is not a typed numeric type.
+func (fa *facade) Bits() (int, bool) {
+	t := fa.basic()
+	if t.Info()&types.IsNumeric == 0 || t.Info()&types.IsUntyped != 0 {
+		return 0, false
+	}
+	return int(fa.pkg.prog.sizes().Sizeof(t)) * 8, true
+}
@@ -98,3 +98,54 @@ func F(){}
 		return true
 	})
 }
+
+func TestBits(t *testing.T) {
+	var src = `package test
+type I8 int8
+type U16 uint16
+type I32 int32
+type F32 float32
+type F64 float64
+type C128 complex128
+type I int
+type UP uintptr
+type B bool
+type S string
+`
+	for _, arch := range []string{"amd64", "386"} {
+		prog, err := aster.NewProgram().
+			AddFile("../_out/bits.go", src).
+			SetSizes(types.SizesFor("gc", arch)).
+			Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var word = 64
+		if arch == "386" {
+			word = 32
+		}
+		var cases = []struct {
+			name string
+			bits int
+			ok   bool
+		}{
+			{"I8", 8, true},
+			{"U16", 16, true},
+			{"I32", 32, true},
+			{"F32", 32, true},
+			{"F64", 64, true},
+			{"C128", 128, true},
+			{"I", word, true},
+			{"UP", word, true},
+			{"B", 0, false},
+			{"S", 0, false},
+		}
+		for _, c := range cases {
+			fa := prog.Lookup(aster.Typ, aster.Basic, c.name)[0]
+			bits, ok := fa.Bits()
+			if bits != c.bits || ok != c.ok {
+				t.Fatalf("%s: Bits of %s: want (%d, %v), got (%d, %v)", arch, c.name, c.bits, c.ok, bits, ok)
+			}
+		}
+	}
+}
@@ -15,9 +15,15 @@
 package aster
 
 import (
+	"errors"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
+	"go/version"
+	"sort"
+	"strconv"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/loader"
@@ -37,12 +43,195 @@ type PackageInfo struct {
 	Errors                []error     // non-nil if the package had errors
 	info                  types.Info  // type-checker deductions.
 	facades               []*facade
+	checked               bool // true if the facades are collected
 }
 
 // A File node represents a Go source file.
 type File struct {
 	*ast.File
 	Filename string
+	pkg      *PackageInfo
+}
+
+// ShadowReport reports a local identifier which shadows a package-level name or an import.
+type ShadowReport struct {
+	Name     string
+	Pos      token.Position // position of the local declaration
+	Shadowed token.Position // position of the package-level declaration or the import
+}
+
+// Shadows returns the local identifiers in the functions of the file,
+// which shadow the package-level names or the imports, in source order.
+func (f *File) Shadows() []ShadowReport {
+	p := f.pkg
+	var list []ShadowReport
+	ast.Inspect(f.File, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Name == "_" {
+			return true
+		}
+		obj := p.info.Defs[ident]
+		if obj == nil || obj.Parent() == nil || obj.Parent() == p.Pkg.Scope() || obj.Parent().Parent() == nil {
+			return true
+		}
+		if _, ok := obj.(*types.PkgName); ok {
+			return true // an import of the file scope
+		}
+		_, shadowed := obj.Parent().Parent().LookupParent(ident.Name, ident.Pos())
+		if shadowed == nil || shadowed.Parent() == types.Universe {
+			return true
+		}
+		if _, ok := shadowed.(*types.PkgName); !ok && shadowed.Parent() != p.Pkg.Scope() {
+			return true // shadows a local identifier
+		}
+		list = append(list, ShadowReport{
+			Name:     ident.Name,
+			Pos:      p.prog.fset.Position(ident.Pos()),
+			Shadowed: p.prog.fset.Position(shadowed.Pos()),
+		})
+		return true
+	})
+	return list
+}
+
+// SetPackageDoc sets the doc comment of the file's package clause,
+// and removes it if text is empty.
+func (f *File) SetPackageDoc(text string) error {
+	if f.Name == nil || !f.Package.IsValid() {
+		return errors.New("aster: SetPackageDoc of file without package clause")
+	}
+	comments := make([]*ast.CommentGroup, 0, len(f.Comments)+1)
+	for _, c := range f.Comments {
+		if c != f.Doc {
+			comments = append(comments, c)
+		}
+	}
+	var pos = f.Package
+	if f.Doc != nil {
+		pos = f.Doc.List[len(f.Doc.List)-1].Pos()
+	} else {
+		// The printer only flushes comments placed before the next token,
+		// so move the package keyword to make room for the doc.
+		f.Package = f.Name.Pos() - 1
+	}
+	f.Doc = nil
+	text = strings.TrimRight(text, "\n")
+	if text != "" {
+		f.Doc = new(ast.CommentGroup)
+		for _, line := range strings.Split(text, "\n") {
+			f.Doc.List = append(f.Doc.List, &ast.Comment{
+				Slash: pos,
+				Text:  strings.TrimRight("// "+line, " "),
+			})
+		}
+		idx := sort.Search(len(comments), func(i int) bool {
+			return comments[i].Pos() > pos
+		})
+		comments = append(comments, nil)
+		copy(comments[idx+1:], comments[idx:])
+		comments[idx] = f.Doc
+	}
+	f.Comments = comments
+	return nil
+}
+
+// ModernizeAny replaces the empty interface type expressions `interface{}` of the file
+// with the predeclared identifier any, and returns the number of the replaced ones.
+// NOTE:
+//  The ones where any is shadowed, or with comments inside the braces, are skipped;
+//  Nothing is replaced, if the file's Go version is before go1.18;
+//  It only edits the AST, the type-checker deductions are not updated.
+func (f *File) ModernizeAny() int {
+	p := f.pkg
+	if v := p.info.FileVersions[f.File]; v != "" && version.Compare(v, "go1.18") < 0 {
+		return 0
+	}
+	anyObj := types.Universe.Lookup("any")
+	var count int
+	astutil.Apply(f.File, func(c *astutil.Cursor) bool {
+		it, ok := c.Node().(*ast.InterfaceType)
+		if !ok || it.Methods == nil || len(it.Methods.List) > 0 || it.Incomplete {
+			return true
+		}
+		for _, cg := range f.Comments {
+			if cg.Pos() > it.Pos() && cg.End() < it.End() {
+				return true
+			}
+		}
+		scope := p.Pkg.Scope().Innermost(it.Pos())
+		if scope == nil {
+			return true
+		}
+		if _, o := scope.LookupParent("any", it.Pos()); o != anyObj {
+			return true
+		}
+		id := &ast.Ident{NamePos: it.Interface, Name: "any"}
+		p.info.Uses[id] = anyObj
+		c.Replace(id)
+		count++
+		return true
+	}, nil)
+	return count
+}
+
+// LineDirective is a //line or /*line*/ directive of a file.
+type LineDirective struct {
+	Pos      token.Position // unadjusted position of the directive
+	Filename string         // empty if the directive keeps the current file name
+	Line     int
+	Column   int // 0 if not specified
+}
+
+// LineDirectives returns the well-formed line directives of the file, in source order.
+// NOTE:
+//  As gofmt does, formatting keeps a //line directive at the start of its line,
+//  but moves an inline /*line*/ directive in front of a declaration onto a line of its own.
+func (f *File) LineDirectives() []LineDirective {
+	var list []LineDirective
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			var text string
+			switch {
+			case strings.HasPrefix(c.Text, "//line "):
+				text = c.Text[len("//line "):]
+			case strings.HasPrefix(c.Text, "/*line "):
+				text = strings.TrimSuffix(c.Text[len("/*line "):], "*/")
+			default:
+				continue
+			}
+			d, ok := parseLineDirective(text)
+			if !ok {
+				continue
+			}
+			d.Pos = f.pkg.prog.fset.PositionFor(c.Pos(), false)
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+// parseLineDirective parses the text "filename:line" or "filename:line:col"
+// as the scanner does.
+func parseLineDirective(text string) (d LineDirective, ok bool) {
+	i := strings.LastIndexByte(text, ':')
+	if i < 0 {
+		return d, false
+	}
+	n, err := strconv.Atoi(text[i+1:])
+	if err != nil || n <= 0 {
+		return d, false
+	}
+	if j := strings.LastIndexByte(text[:i], ':'); j >= 0 {
+		if line, err := strconv.Atoi(text[j+1 : i]); err == nil {
+			if line <= 0 {
+				return d, false
+			}
+			d.Filename, d.Line, d.Column = text[:j], line, n
+			return d, true
+		}
+	}
+	d.Filename, d.Line = text[:i], n
+	return d, true
 }
 
 // newPackageInfo creates a package info.
@@ -62,6 +251,304 @@ func (p *PackageInfo) String() string {
 	return p.Pkg.Path()
 }
 
+// Doc returns the package doc comment, which is
+// collected from all files of the package as go/doc does.
+func (p *PackageInfo) Doc() string {
+	var doc string
+	for _, f := range p.files {
+		if f.Doc == nil {
+			continue
+		}
+		text := f.Doc.Text()
+		if doc == "" {
+			doc = text
+		} else {
+			doc += "\n" + text
+		}
+	}
+	return doc
+}
+
+// Files returns the syntax trees of the package's files.
+func (p *PackageInfo) Files() []*File {
+	files := make([]*File, len(p.files))
+	for i, f := range p.files {
+		files[i] = &File{
+			File:     f,
+			Filename: p.prog.filename(f),
+			pkg:      p,
+		}
+	}
+	return files
+}
+
+// TypeOfExpr returns the type of expression expr,
+// using the deductions already computed by the type-checker.
+// NOTE: return false, if expr is not part of the package.
+func (p *PackageInfo) TypeOfExpr(expr ast.Expr) (types.Type, bool) {
+	t := p.info.TypeOf(expr)
+	return t, t != nil
+}
+
+// ConstValueOf returns the value of the constant which the identifier refers to,
+// such as the array length `maxLen` of `[maxLen]byte`.
+// NOTE: return false, if ident is not a constant of the package.
+func (p *PackageInfo) ConstValueOf(ident *ast.Ident) (constant.Value, bool) {
+	if c, ok := p.info.ObjectOf(ident).(*types.Const); ok {
+		return c.Val(), true
+	}
+	return nil, false
+}
+
+// ResolveMethodExpr finds the declaring method of the method expression `T.Method`
+// or the method value `x.Method`, which may be used as a first-class value.
+// NOTE: return false, if expr is not a method selector of the package.
+func (p *PackageInfo) ResolveMethodExpr(expr ast.Expr) (Facade, bool) {
+	se, ok := astutil.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return nil, false
+	}
+	sel, ok := p.info.Selections[se]
+	if !ok || (sel.Kind() != types.MethodExpr && sel.Kind() != types.MethodVal) {
+		return nil, false
+	}
+	fn := sel.Obj().(*types.Func).Origin()
+	return p.prog.facadeOf(fn)
+}
+
+// ResolveCompositeLit returns the facade of the defined struct type of the composite literal,
+// such as `T{A: 1}` or an element `{A: 1}` of `[]*T{...}`, and its field values keyed by field name.
+// The positional values are keyed by the names of the fields in order.
+// NOTE: Return false, if cl is not a literal of a defined struct type of the program.
+func (p *PackageInfo) ResolveCompositeLit(cl *ast.CompositeLit) (Facade, map[string]ast.Expr, bool) {
+	typ := p.info.TypeOf(cl)
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := types.Unalias(typ).(*types.Named)
+	if !ok {
+		return nil, nil, false
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, nil, false
+	}
+	fa, ok := p.prog.facadeOf(named.Origin().Obj())
+	if !ok {
+		return nil, nil, false
+	}
+	values := make(map[string]ast.Expr, len(cl.Elts))
+	for i, elt := range cl.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				values[key.Name] = kv.Value
+			}
+		} else if i < st.NumFields() {
+			values[st.Field(i).Name()] = elt
+		}
+	}
+	return fa, values, true
+}
+
+// EnclosingFunc returns the function or method whose declaration contains pos,
+// including the positions in its function literals.
+// NOTE: Return false, if pos is not in a function declaration of the package.
+func (p *PackageInfo) EnclosingFunc(pos token.Pos) (Facade, bool) {
+	nodes, _ := p.pathEnclosingInterval(pos, pos)
+	for _, n := range nodes {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			fa, idx := p.getFacade(decl.Name)
+			return fa, idx != -1
+		}
+	}
+	return nil, false
+}
+
+// ConcreteTypeAt returns the concrete type of the value, which the interface variable ident holds,
+// such as *bytes.Buffer for w after `var w io.Writer = &bytes.Buffer{}`.
+// It is a heuristic within the enclosing function, or the file for a package-level variable:
+// the last assignment to the variable before ident, or of ident itself, gives the value,
+// regardless of the branches, loops and references that may change it at run time.
+// NOTE: Return false, if ident is not an interface variable, or the value is not found or not concrete.
+func (p *PackageInfo) ConcreteTypeAt(ident *ast.Ident) (TypeNode, bool) {
+	obj, ok := p.info.ObjectOf(ident).(*types.Var)
+	if !ok || obj.IsField() || !types.IsInterface(obj.Type()) {
+		return TypeNode{}, false
+	}
+	var region ast.Node = p.fileOf(ident.Pos())
+	nodes, _ := p.pathEnclosingInterval(ident.Pos(), ident.End())
+	for _, n := range nodes {
+		if fn, ok := n.(*ast.FuncLit); ok {
+			region = fn.Body
+			break
+		}
+		if fn, ok := n.(*ast.FuncDecl); ok && fn.Body != nil {
+			region = fn.Body
+			break
+		}
+	}
+	if region == nil {
+		return TypeNode{}, false
+	}
+	var value ast.Expr
+	var lastPos token.Pos
+	assign := func(lhs *ast.Ident, rhs ast.Expr) {
+		if p.info.ObjectOf(lhs) == obj && lhs.Pos() <= ident.Pos() && lhs.Pos() >= lastPos {
+			value, lastPos = rhs, lhs.Pos()
+		}
+	}
+	ast.Inspect(region, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ValueSpec:
+			if len(x.Names) == len(x.Values) {
+				for i, name := range x.Names {
+					assign(name, x.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			if len(x.Lhs) == len(x.Rhs) {
+				for i, lhs := range x.Lhs {
+					if id, ok := ast.Unparen(lhs).(*ast.Ident); ok {
+						assign(id, x.Rhs[i])
+					}
+				}
+			}
+		}
+		return true
+	})
+	if value == nil {
+		return TypeNode{}, false
+	}
+	typ := p.info.TypeOf(value)
+	if typ == nil || types.IsInterface(typ) {
+		return TypeNode{}, false
+	}
+	if b, ok := typ.(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+		if b.Kind() == types.UntypedNil {
+			return TypeNode{}, false
+		}
+		typ = types.Default(typ)
+	}
+	return TypeNode{Node: value, Type: typ}, true
+}
+
+// InitFuncs returns the init functions of the package, in the order of files and declarations.
+func (p *PackageInfo) InitFuncs() []Facade {
+	p.check() // make sure the facades of dependencies are collected
+	var list []Facade
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != "init" {
+				continue
+			}
+			if fa, idx := p.getFacade(fn.Name); idx != -1 {
+				list = append(list, fa)
+			}
+		}
+	}
+	return list
+}
+
+// AssignTypes returns the types of the values assigned to each LHS of assign,
+// expanding multi-value calls and comma-ok forms.
+// The blank identifier gets the type of the discarded value,
+// with untyped constants converted to their default types.
+// NOTE: The element is nil, if the type of the corresponding value is unknown.
+func (p *PackageInfo) AssignTypes(assign *ast.AssignStmt) []types.Type {
+	return p.assignTypes(len(assign.Lhs), assign.Rhs)
+}
+
+func (p *PackageInfo) assignTypes(n int, values []ast.Expr) []types.Type {
+	list := make([]types.Type, n)
+	if len(values) == n {
+		for i, rhs := range values {
+			list[i] = p.info.TypeOf(rhs)
+			if b, ok := list[i].(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+				list[i] = types.Default(b)
+			}
+		}
+		return list
+	}
+	if len(values) != 1 {
+		return list
+	}
+	switch t := p.info.TypeOf(values[0]).(type) {
+	case *types.Tuple:
+		for i := 0; i < t.Len() && i < len(list); i++ {
+			list[i] = t.At(i).Type()
+		}
+	case nil:
+	default:
+		// comma-ok form: v, ok = m[k], x.(T), <-ch
+		list[0] = t
+		if len(list) == 2 {
+			list[1] = types.Typ[types.Bool]
+		}
+	}
+	return list
+}
+
+// TypeNode is a type with the expression that produces it.
+type TypeNode struct {
+	Node ast.Expr
+	Type types.Type
+}
+
+// TypeSwitchCases returns the types of the cases per clause of the type switch, in source order,
+// such as int and string for `case int, string:`, or untyped nil for `case nil:`.
+// The element of the default clause is nil.
+func (p *PackageInfo) TypeSwitchCases(sw *ast.TypeSwitchStmt) [][]TypeNode {
+	clauses := make([][]TypeNode, len(sw.Body.List))
+	for i, stmt := range sw.Body.List {
+		for _, expr := range stmt.(*ast.CaseClause).List {
+			clauses[i] = append(clauses[i], TypeNode{Node: expr, Type: p.info.TypeOf(expr)})
+		}
+	}
+	return clauses
+}
+
+// BlankAssignTypes returns the types of the values discarded into the blank identifier,
+// by assignments and variable declarations across the package, in source order.
+// For a multi-value call, such as `_, err = f()`, Node is the call.
+// It helps to detect the ignored errors.
+func (p *PackageInfo) BlankAssignTypes() []TypeNode {
+	var list []TypeNode
+	collect := func(lhs []ast.Expr, values []ast.Expr) {
+		if len(values) == 0 {
+			return
+		}
+		for i, typ := range p.assignTypes(len(lhs), values) {
+			if id, ok := lhs[i].(*ast.Ident); !ok || id.Name != "_" {
+				continue
+			}
+			node := values[0]
+			if len(values) == len(lhs) {
+				node = values[i]
+			}
+			list = append(list, TypeNode{Node: node, Type: typ})
+		}
+	}
+	for _, f := range p.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.AssignStmt:
+				if x.Tok == token.ASSIGN || x.Tok == token.DEFINE {
+					collect(x.Lhs, x.Rhs)
+				}
+			case *ast.ValueSpec:
+				lhs := make([]ast.Expr, len(x.Names))
+				for i, id := range x.Names {
+					lhs[i] = id
+				}
+				collect(lhs, x.Values)
+			}
+			return true
+		})
+	}
+	return list
+}
+
 // pathEnclosingInterval returns the PackageInfo and ast.Node that
 // contain source interval [start, end), and all the node's ancestors
 // up to the AST root.  It searches all ast.files in the package.
@@ -77,7 +564,7 @@ func (p *PackageInfo) pathEnclosingInterval(start, end token.Pos) (path []ast.No
 			// (Use parser.AllErrors to prevent that.)
 			continue
 		}
-		if !tokenFileContainsPos(p.prog.fset.File(f.Pos()), start) {
+		if !p.fileContainsPos(f, start) {
 			continue
 		}
 		if path, exact := astutil.PathEnclosingInterval(f, start, end); path != nil {
@@ -87,6 +574,49 @@ func (p *PackageInfo) pathEnclosingInterval(start, end token.Pos) (path []ast.No
 	return nil, false
 }
 
+// fileOf returns the file containing pos, or nil.
+func (p *PackageInfo) fileOf(pos token.Pos) *ast.File {
+	for _, f := range p.files {
+		if f.Pos().IsValid() && p.fileContainsPos(f, pos) {
+			return f
+		}
+	}
+	return nil
+}
+
+// fileContainsPos reports whether pos is in the source of the file,
+// or of the declarations appended to it.
+func (p *PackageInfo) fileContainsPos(f *ast.File, pos token.Pos) bool {
+	if tokenFileContainsPos(p.prog.fset.File(f.Pos()), pos) {
+		return true
+	}
+	for _, tf := range p.prog.appended[f] {
+		if tokenFileContainsPos(tf, pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeComments removes the comment groups from the file comments.
+func (p *PackageInfo) removeComments(groups ...*ast.CommentGroup) {
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		f := p.fileOf(g.Pos())
+		if f == nil {
+			continue
+		}
+		for i, c := range f.Comments {
+			if c == g {
+				f.Comments = append(f.Comments[:i:i], f.Comments[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
 // docComment returns the doc for an identifier.
 func (p *PackageInfo) docComment(id *ast.Ident) *ast.CommentGroup {
 	nodes, _ := p.pathEnclosingInterval(id.Pos(), id.End())
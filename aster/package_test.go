@@ -0,0 +1,618 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestTypeOfExpr(t *testing.T) {
+	var src = `package test
+type S struct{
+	A int
+}
+var s = S{A: 1}
+`
+	prog, err := aster.LoadFile("../_out/type_of_expr.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var lit *ast.CompositeLit
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if cl, ok := n.(*ast.CompositeLit); ok {
+				lit = cl
+				return false
+			}
+			return true
+		})
+	}
+	if lit == nil {
+		t.Fatal("composite literal not found")
+	}
+	typ, ok := pkg.TypeOfExpr(lit)
+	if !ok {
+		t.Fatal("TypeOfExpr: not found")
+	}
+	if typ.String() != "test.S" {
+		t.Fatalf("TypeOfExpr: want: test.S, got: %v", typ)
+	}
+	if _, ok = pkg.TypeOfExpr(&ast.Ident{Name: "s"}); ok {
+		t.Fatal("TypeOfExpr: want not found for a foreign expression")
+	}
+}
+
+func TestConstValueOf(t *testing.T) {
+	var src = `package test
+const maxLen = 1 << 4
+type Buf [maxLen]byte
+var n = 3
+`
+	prog, err := aster.LoadFile("../_out/const_value_of.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := prog.Lookup(aster.Typ, aster.Array, "Buf")[0]
+	if n := buf.Len(); n != 16 {
+		t.Fatalf("Len: want 16, got %d", n)
+	}
+	pkg := prog.Package("test")
+	var idents []*ast.Ident
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if at, ok := n.(*ast.ArrayType); ok {
+				if id, ok := at.Len.(*ast.Ident); ok {
+					idents = append(idents, id)
+				}
+			}
+			if vs, ok := n.(*ast.ValueSpec); ok && vs.Names[0].Name == "n" {
+				idents = append(idents, vs.Names[0])
+			}
+			return true
+		})
+	}
+	if len(idents) != 2 {
+		t.Fatalf("want 2 identifiers, got %d", len(idents))
+	}
+	v, ok := pkg.ConstValueOf(idents[0])
+	if !ok || v.Kind() != constant.Int || v.String() != "16" {
+		t.Fatalf("ConstValueOf(maxLen): want 16, got %v, %v", v, ok)
+	}
+	if _, ok = pkg.ConstValueOf(idents[1]); ok {
+		t.Fatal("ConstValueOf(n): want false for a variable")
+	}
+}
+
+func TestResolveMethodExpr(t *testing.T) {
+	var src = `package test
+type T struct{ N int }
+func (T) Get() int { return 0 }
+func (*T) Set(n int) {}
+func use() {
+	var x T
+	get := T.Get
+	set := x.Set
+	f := (*T).Set
+	n := x.N
+	_, _, _, _ = get, set, f, n
+}
+`
+	prog, err := aster.LoadFile("../_out/resolve_method_expr.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var values []ast.Expr
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if as, ok := n.(*ast.AssignStmt); ok && as.Tok == token.DEFINE {
+				values = append(values, as.Rhs[0])
+			}
+			return true
+		})
+	}
+	if len(values) != 4 {
+		t.Fatalf("want 4 values, got %d", len(values))
+	}
+	for i, want := range []string{"Get", "Set", "Set"} {
+		fa, ok := pkg.ResolveMethodExpr(values[i])
+		if !ok || fa.Name() != want || !fa.IsMethod() {
+			t.Fatalf("ResolveMethodExpr(%d): want method %s, got %v, %v", i, want, fa, ok)
+		}
+	}
+	if _, ok := pkg.ResolveMethodExpr(values[3]); ok {
+		t.Fatal("ResolveMethodExpr: want false for a field selector")
+	}
+}
+
+func TestShadows(t *testing.T) {
+	var src = `package test
+import "strings"
+var err error
+func f(s string) error {
+	strings := []string{s}
+	if err := g(); err != nil {
+		return err
+	}
+	for _, len := range strings {
+		_ = len
+	}
+	return nil
+}
+func g() (err error) { return }
+`
+	prog, err := aster.LoadFile("../_out/shadows.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := prog.Package("test").Files()[0].Shadows()
+	var want = []struct {
+		name          string
+		line, shadows int
+	}{
+		{"strings", 5, 2},
+		{"err", 6, 3},
+		{"err", 14, 3},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("Shadows: want %d reports, got %v", len(want), reports)
+	}
+	for i, w := range want {
+		r := reports[i]
+		if r.Name != w.name || r.Pos.Line != w.line || r.Shadowed.Line != w.shadows {
+			t.Fatalf("Shadows[%d]: want %s at line %d shadowing line %d, got %+v", i, w.name, w.line, w.shadows, r)
+		}
+	}
+}
+
+func TestEnclosingFunc(t *testing.T) {
+	var src = `package test
+type T struct{ n int }
+func (t *T) Inc() {
+	go func() {
+		t.n++
+	}()
+}
+func New() *T { return &T{} }
+var x = 1
+`
+	prog, err := aster.LoadFile("../_out/enclosing_func.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var inc, lit token.Pos
+	ast.Inspect(pkg.Files()[0].File, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.IncDecStmt:
+			inc = x.Pos()
+		case *ast.CompositeLit:
+			lit = x.Pos()
+		}
+		return true
+	})
+	fa, ok := pkg.EnclosingFunc(inc)
+	if !ok || fa.Name() != "Inc" || !fa.IsMethod() {
+		t.Fatalf("EnclosingFunc(t.n++): want method Inc, got %v, %v", fa, ok)
+	}
+	if fa, ok = pkg.EnclosingFunc(lit); !ok || fa.Name() != "New" {
+		t.Fatalf("EnclosingFunc(&T{}): want New, got %v, %v", fa, ok)
+	}
+	x := pkg.Lookup(aster.Var, 0, "x")[0]
+	if _, ok = pkg.EnclosingFunc(x.Ident().Pos()); ok {
+		t.Fatal("EnclosingFunc(x): want false for a package-level variable")
+	}
+}
+
+func TestAssignTypes(t *testing.T) {
+	var src = `package test
+func f() (int, error) { return 0, nil }
+func g() {
+	a, b := f()
+	m := map[string]bool{}
+	v, ok := m["x"]
+	_, _, _, _ = a, b, v, ok
+}
+`
+	prog, err := aster.LoadFile("../_out/assign_types.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var assigns []*ast.AssignStmt
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if as, ok := n.(*ast.AssignStmt); ok && len(as.Lhs) == 2 {
+				assigns = append(assigns, as)
+			}
+			return true
+		})
+	}
+	if len(assigns) != 2 {
+		t.Fatalf("want 2 assignments, got %d", len(assigns))
+	}
+	var want = [][]string{
+		{"int", "error"},
+		{"bool", "bool"},
+	}
+	for i, as := range assigns {
+		list := pkg.AssignTypes(as)
+		if len(list) != 2 {
+			t.Fatalf("AssignTypes: want 2 types, got %d", len(list))
+		}
+		for j, typ := range list {
+			if typ == nil || typ.String() != want[i][j] {
+				t.Fatalf("AssignTypes[%d][%d]: want: %s, got: %v", i, j, want[i][j], typ)
+			}
+		}
+	}
+}
+
+func TestPackageDoc(t *testing.T) {
+	var src = `package test
+// A comment
+type A int
+`
+	prog, err := aster.LoadFile("../_out/package_doc.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	if doc := pkg.Doc(); doc != "" {
+		t.Fatalf("Doc: want empty, got %q", doc)
+	}
+	f := pkg.Files()[0]
+	if err = f.SetPackageDoc("Package test is a fixture.\nSecond line."); err != nil {
+		t.Fatal(err)
+	}
+	var want = "Package test is a fixture.\nSecond line.\n"
+	if doc := pkg.Doc(); doc != want {
+		t.Fatalf("Doc: want %q, got %q", want, doc)
+	}
+	code, err := pkg.FormatNode(f.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	reparsed, err := parser.ParseFile(token.NewFileSet(), "", code, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := reparsed.Doc.Text(); doc != want {
+		t.Fatalf("reparsed doc: want %q, got %q", want, doc)
+	}
+	if len(reparsed.Comments) != 2 {
+		t.Fatalf("reparsed comments: want 2, got %d", len(reparsed.Comments))
+	}
+	if err = f.SetPackageDoc("Package test v2."); err != nil {
+		t.Fatal(err)
+	}
+	if doc := pkg.Doc(); doc != "Package test v2.\n" {
+		t.Fatalf("Doc: want %q, got %q", "Package test v2.\n", doc)
+	}
+}
+
+func TestTypeSwitchCases(t *testing.T) {
+	var src = `package test
+import "io"
+func f(x interface{}) {
+	switch v := x.(type) {
+	case io.Reader:
+		_ = v
+	case int, *string:
+	default:
+	}
+}
+`
+	prog, err := aster.LoadFile("../_out/type_switch_cases.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var sw *ast.TypeSwitchStmt
+	ast.Inspect(pkg.Files()[0].File, func(n ast.Node) bool {
+		if x, ok := n.(*ast.TypeSwitchStmt); ok {
+			sw = x
+		}
+		return sw == nil
+	})
+	clauses := pkg.TypeSwitchCases(sw)
+	var want = [][]string{{"io.Reader"}, {"int", "*string"}, nil}
+	if len(clauses) != len(want) {
+		t.Fatalf("TypeSwitchCases: want %d clauses, got %d", len(want), len(clauses))
+	}
+	for i, clause := range clauses {
+		if len(clause) != len(want[i]) {
+			t.Fatalf("TypeSwitchCases[%d]: want %v, got %v", i, want[i], clause)
+		}
+		for j, tn := range clause {
+			if tn.Type == nil || tn.Type.String() != want[i][j] {
+				t.Fatalf("TypeSwitchCases[%d][%d]: want %s, got %v", i, j, want[i][j], tn.Type)
+			}
+		}
+	}
+}
+
+func TestBlankAssignTypes(t *testing.T) {
+	var src = `package test
+import "os"
+var _ = 1.5
+func f() (int, error) { return 0, nil }
+func g() {
+	_ = os.Remove("x")
+	n, _ := f()
+	_, _ = n, "s"
+}
+`
+	prog, err := aster.LoadFile("../_out/blank_assign_types.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := prog.Package("test").BlankAssignTypes()
+	var want = []string{"float64", "error", "error", "int", "string"}
+	if len(list) != len(want) {
+		t.Fatalf("BlankAssignTypes: want %d, got %v", len(want), list)
+	}
+	var ignored int
+	for i, tn := range list {
+		if tn.Type == nil || tn.Type.String() != want[i] {
+			t.Fatalf("BlankAssignTypes[%d]: want: %s, got: %v", i, want[i], tn.Type)
+		}
+		if tn.Type.String() == "error" {
+			if _, ok := tn.Node.(*ast.CallExpr); !ok {
+				t.Fatalf("BlankAssignTypes[%d]: want a call node, got %T", i, tn.Node)
+			}
+			ignored++
+		}
+	}
+	if ignored != 2 {
+		t.Fatalf("ignored errors: want 2, got %d", ignored)
+	}
+}
+
+func TestLineDirectives(t *testing.T) {
+	var src = `package test
+
+//line tmpl.go:10
+type A int
+
+/*line tmpl.go:20:5*/ type B int
+
+//line bad
+type C int
+`
+	prog, err := aster.LoadFile("../_out/line_directives.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	f := pkg.Files()[0]
+	list := f.LineDirectives()
+	if len(list) != 2 {
+		t.Fatalf("LineDirectives: want 2, got %+v", list)
+	}
+	if d := list[0]; d.Filename != "tmpl.go" || d.Line != 10 || d.Column != 0 || d.Pos.Line != 3 || d.Pos.Column != 1 {
+		t.Fatalf("LineDirectives[0]: got %+v", d)
+	}
+	if d := list[1]; d.Filename != "tmpl.go" || d.Line != 20 || d.Column != 5 || d.Pos.Line != 6 {
+		t.Fatalf("LineDirectives[1]: got %+v", d)
+	}
+	if groups := pkg.FacadesByFile(); len(groups) != 1 {
+		t.Fatalf("FacadesByFile: want 1 file, got %d", len(groups))
+	}
+	code, err := pkg.FormatNode(f.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	fset := token.NewFileSet()
+	reparsed, err := parser.ParseFile(fset, "line_directives.go", code, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := fset.Position(reparsed.Decls[0].Pos()); pos.Filename != "tmpl.go" || pos.Line != 10 {
+		t.Fatalf("reparsed A: want tmpl.go:10, got %s", pos)
+	}
+	if d := reparsed.Comments[0].List[0].Text; d != "//line tmpl.go:10" {
+		t.Fatalf("reparsed directive: got %q", d)
+	}
+}
+
+func TestInitFuncs(t *testing.T) {
+	var files = map[string]string{
+		"a.go": "package inits\nvar A int\nfunc init() { A = 1 }\n",
+		"b.go": "package inits\nvar B int\nfunc init() { B = 2 }\ntype T struct{}\nfunc (T) init() {}\n",
+	}
+	dir := "../_out/init_funcs"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog, err := aster.NewProgram().Import(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.InitialPackages()[0]
+	list := pkg.InitFuncs()
+	if len(list) != 2 {
+		t.Fatalf("InitFuncs: want 2 functions, got %d", len(list))
+	}
+	for i, want := range []string{"a.go", "b.go"} {
+		var filename string
+		for _, f := range pkg.Files() {
+			if pos := list[i].Ident().Pos(); f.Pos() <= pos && pos < f.End() {
+				filename = f.Filename
+			}
+		}
+		if list[i].Name() != "init" || filepath.Base(filename) != want {
+			t.Fatalf("InitFuncs[%d]: want init in %s, got %s in %s", i, want, list[i].Name(), filename)
+		}
+	}
+}
+
+func TestResolveCompositeLit(t *testing.T) {
+	var src = `package test
+type Point struct{ X, Y int }
+type Line struct {
+	From, To Point
+	Name     string
+}
+var L = Line{Name: "l", From: Point{1, 2}}
+var Ps = []*Point{{X: 3}}
+`
+	prog, err := aster.LoadFile("../_out/resolve_composite_lit.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var lits []*ast.CompositeLit
+	for _, f := range pkg.Files() {
+		ast.Inspect(f.File, func(n ast.Node) bool {
+			if cl, ok := n.(*ast.CompositeLit); ok {
+				lits = append(lits, cl)
+			}
+			return true
+		})
+	}
+	var want = []struct {
+		typ    string
+		fields string
+		ok     bool
+	}{
+		{"Line", "From:Point{…},Name:\"l\"", true},
+		{"Point", "X:1,Y:2", true},
+		{"", "", false},
+		{"Point", "X:3", true},
+	}
+	if len(lits) != len(want) {
+		t.Fatalf("ResolveCompositeLit: want %d literals, got %d", len(want), len(lits))
+	}
+	for i, cl := range lits {
+		fa, values, ok := pkg.ResolveCompositeLit(cl)
+		if ok != want[i].ok {
+			t.Fatalf("ResolveCompositeLit[%d]: want %v, got %v", i, want[i].ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		var fields []string
+		for j := 0; j < fa.NumFields(); j++ {
+			name := fa.Field(j).Name()
+			if v, ok := values[name]; ok {
+				fields = append(fields, name+":"+types.ExprString(v))
+			}
+		}
+		if fa.Name() != want[i].typ || strings.Join(fields, ",") != want[i].fields {
+			t.Fatalf("ResolveCompositeLit[%d]: want %s %s, got %s %s", i, want[i].typ, want[i].fields, fa.Name(), strings.Join(fields, ","))
+		}
+	}
+}
+
+func TestConcreteTypeAt(t *testing.T) {
+	var src = `package test
+import (
+	"bytes"
+	"io"
+	"os"
+)
+func write(r io.Reader) {
+	var w io.Writer = &bytes.Buffer{}
+	w.Write(nil)
+	w = os.Stdout
+	w.Write(nil)
+	w = io.Discard
+	w.Write(nil)
+	var n interface{} = 1
+	_ = n
+	_ = r
+}
+`
+	prog, err := aster.LoadFile("../_out/concrete_type_at.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	var got []string
+	ast.Inspect(pkg.Files()[0].File, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && (id.Name == "w" || id.Name == "n" || id.Name == "r") {
+			if tn, ok := pkg.ConcreteTypeAt(id); ok {
+				got = append(got, id.Name+":"+tn.Type.String())
+			} else {
+				got = append(got, id.Name+":-")
+			}
+		}
+		return true
+	})
+	want := "r:-,w:*bytes.Buffer,w:*bytes.Buffer,w:*os.File,w:*os.File,w:-,w:-,n:int,n:int,r:-"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("ConcreteTypeAt: want %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestModernizeAny(t *testing.T) {
+	var src = `package test
+var M map[string]interface{}
+func Print(args ...interface{}) {}
+func Shadowed(any int) { var x interface{} = any; _ = x }
+func Commented() interface{ /* empty */ } { return nil }
+type Getter interface{ Get() interface{} }
+`
+	prog, err := aster.LoadFile("../_out/modernize_any.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	file := pkg.Files()[0]
+	if n := file.ModernizeAny(); n != 3 {
+		t.Fatalf("ModernizeAny: want 3, got %d", n)
+	}
+	code, err := pkg.FormatNode(file.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"var M map[string]any",
+		"func Print(args ...any) {}",
+		"var x interface{} = any",
+		"/* empty */",
+		"type Getter interface{ Get() any }",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("ModernizeAny: want %q in code", want)
+		}
+	}
+	if strings.Contains(code, "Commented() any") {
+		t.Fatal("ModernizeAny: want the commented interface{} skipped")
+	}
+	if n := file.ModernizeAny(); n != 0 {
+		t.Fatalf("ModernizeAny again: want 0, got %d", n)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"runtime"
 	"strings"
 
 	"golang.org/x/tools/go/loader"
@@ -59,10 +60,25 @@ type Program struct {
 
 	filenames map[*ast.File]string
 
+	// appended contains the sources of the declarations appended to the files,
+	// such as by AddGeneratedMethod.
+	appended map[*ast.File][]*token.File
+
+	// sources contains the source code of the parsed files, by AddFile and AddGeneratedMethod,
+	// or read from the file system on demand for the imported packages.
+	sources map[*token.File][]byte
+
 	// We use token.File, not filename, since a file may appear to
 	// belong to multiple packages and be parsed more than once.
 	// token.File captures this distinction; filename does not.
 	filesToUpdate map[*token.File]bool
+
+	// keepCgoGenerated reports whether the facades of the declarations generated by cgo are collected.
+	keepCgoGenerated bool
+
+	// keepFieldGroups reports whether the struct fields declared in a group, such as `A, B int`,
+	// are kept in the group until one of them is edited.
+	keepFieldGroups bool
 }
 
 // LoadFile parses the source code of a single Go file and loads a new program.
@@ -103,6 +119,8 @@ func LoadPkgsWithTests(pkgPath ...string) (*Program, error) {
 func NewProgram() *Program {
 	prog := new(Program)
 	prog.filenames = make(map[*ast.File]string, 128)
+	prog.appended = make(map[*ast.File][]*token.File)
+	prog.sources = make(map[*token.File][]byte)
 	prog.filesToUpdate = make(map[*token.File]bool, 128)
 	prog.conf.ParserMode = parser.ParseComments
 	// Optimization: don't type-check the bodies of functions in our
@@ -141,7 +159,12 @@ func NewProgram() *Program {
 //
 func (prog *Program) AddFile(filename string, src interface{}) (itself *Program) {
 	if !prog.initiated && prog.initialError == nil {
-		f, err := prog.conf.ParseFile(filename, src)
+		code, err := readSource(filename, src)
+		if err != nil {
+			prog.initialError = err
+			return prog
+		}
+		f, err := prog.conf.ParseFile(filename, code)
 		if err != nil {
 			prog.initialError = err
 		} else {
@@ -149,6 +172,7 @@ func (prog *Program) AddFile(filename string, src interface{}) (itself *Program)
 				filename = autoFilename(f)
 			}
 			prog.filenames[f] = filename
+			prog.sources[prog.conf.Fset.File(f.Pos())] = code
 			prog.conf.CreateFromFiles(f.Name.Name, f)
 		}
 	}
@@ -185,6 +209,43 @@ func (prog *Program) ImportWithTests(pkgPath ...string) (itself *Program) {
 	return prog
 }
 
+// SetSizes sets the sizes of the target platform for type-checking,
+// the default is the sizes of the gc compiler on the current GOARCH.
+func (prog *Program) SetSizes(sizes types.Sizes) (itself *Program) {
+	if !prog.initiated {
+		prog.conf.TypeChecker.Sizes = sizes
+	}
+	return prog
+}
+
+// SetSkipCgoGenerated sets whether to skip the facades of the declarations generated by cgo,
+// such as _Ctype_int and _Cfunc_free, the default is true.
+// The packages importing "C" are preprocessed by cgo when loaded as dependencies or by Import.
+func (prog *Program) SetSkipCgoGenerated(skip bool) (itself *Program) {
+	if !prog.initiated {
+		prog.keepCgoGenerated = !skip
+	}
+	return prog
+}
+
+// SetKeepFieldGroups sets whether to keep the struct fields declared in a group, such as `A, B int`,
+// when the struct is read, and to split the group only when one of them is edited,
+// the default is false, which expands each group into one field per name on the first access.
+func (prog *Program) SetKeepFieldGroups(keep bool) (itself *Program) {
+	if !prog.initiated {
+		prog.keepFieldGroups = keep
+	}
+	return prog
+}
+
+// sizes returns the sizes of the target platform.
+func (prog *Program) sizes() types.Sizes {
+	if prog.conf.TypeChecker.Sizes != nil {
+		return prog.conf.TypeChecker.Sizes
+	}
+	return types.SizesFor("gc", runtime.GOARCH)
+}
+
 // Load loads the program's packages,
 // and loads their dependencies packages as needed.
 //
@@ -318,6 +379,33 @@ func (prog *Program) pathEnclosingInterval(start, end token.Pos) (pkg *PackageIn
 	return nil, nil, false
 }
 
+// importer returns an importer of the loaded packages.
+func (prog *Program) importer() types.Importer {
+	return importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		for pkg := range prog.allPackages {
+			if pkg.Path() == path {
+				return pkg, nil
+			}
+		}
+		return nil, fmt.Errorf("aster: can not import %q", path)
+	})
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// filename returns the name of the local file that f is rewritten to.
+func (prog *Program) filename(f *ast.File) string {
+	if name, ok := prog.filenames[f]; ok {
+		return name
+	}
+	return prog.fset.File(f.Pos()).Name()
+}
+
 func containsHardErrors(errors []error) bool {
 	for _, err := range errors {
 		if err, ok := err.(types.Error); ok && err.Soft {
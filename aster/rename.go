@@ -0,0 +1,572 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Rename renames the type, function, method, variable or constant,
+// and updates its references in all initial packages,
+// including the embedded fields of a renamed type.
+// It rejects the name colliding with a declaration in the defining scope,
+// the name shadowing or shadowed at a reference,
+// and the unexported name of an object referenced by other packages.
+// NOTE: The types.Object keeps the old name, so reload the program for further type checking.
+func (prog *Program) Rename(fa Facade, newName string) error {
+	f := fa.(*facade)
+	switch f.ObjKind() {
+	case Typ, Fun, Var, Con:
+	default:
+		return fmt.Errorf("aster: can not rename %s %s", f.ObjKind(), f.Name())
+	}
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return fmt.Errorf("aster: invalid name %q", newName)
+	}
+	if newName == f.Name() {
+		return nil
+	}
+	obj := f.obj
+	if err := f.renameConflict(newName); err != nil {
+		return err
+	}
+	// the embedded fields of the renamed type are renamed too
+	objs := map[types.Object]bool{obj: true}
+	if _, ok := obj.(*types.TypeName); ok {
+		for _, pkg := range prog.InitialPackages() {
+			for _, def := range pkg.info.Defs {
+				if v, ok := def.(*types.Var); ok && v.Embedded() && embeddedTypeName(v.Type()) == obj {
+					objs[v] = true
+				}
+			}
+		}
+	}
+	var idents []*ast.Ident
+	for _, pkg := range prog.InitialPackages() {
+		for id, use := range pkg.info.Uses {
+			if !objs[use] {
+				continue
+			}
+			if pkg != f.pkg && !token.IsExported(newName) {
+				return fmt.Errorf("aster: can not unexport %s, which is referenced by package %s", f.Name(), pkg.Pkg.Path())
+			}
+			if use == obj && pkg == f.pkg {
+				if err := f.renameShadowed(id, newName); err != nil {
+					return err
+				}
+			}
+			idents = append(idents, id)
+		}
+		for id, def := range pkg.info.Defs {
+			if objs[def] && def != obj {
+				idents = append(idents, id)
+			}
+		}
+	}
+	f.ident.Name = newName
+	for _, id := range idents {
+		id.Name = newName
+	}
+	return nil
+}
+
+// renameConflict checks whether the new name collides with a declaration in the defining scope.
+func (fa *facade) renameConflict(newName string) error {
+	obj := fa.obj
+	scope := obj.Parent()
+	if scope == nil {
+		// method
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return fmt.Errorf("aster: can not rename %s", fa.Name())
+		}
+		if o, _, _ := types.LookupFieldOrMethod(recv.Type(), true, obj.Pkg(), newName); o != nil {
+			return fmt.Errorf("aster: %s conflicts with %s", newName, o)
+		}
+		return nil
+	}
+	if o := scope.Lookup(newName); o != nil {
+		return fmt.Errorf("aster: %s conflicts with %s", newName, o)
+	}
+	if scope == obj.Pkg().Scope() {
+		for _, file := range fa.pkg.files {
+			if o := fa.pkg.info.Scopes[file].Lookup(newName); o != nil {
+				return fmt.Errorf("aster: %s conflicts with %s", newName, o)
+			}
+		}
+	}
+	// the references to the outer objects would be shadowed
+	for id, use := range fa.pkg.info.Uses {
+		if use.Name() != newName || use.Parent() == nil || !isInnerScope(scope, use.Parent()) {
+			continue
+		}
+		if scope == obj.Pkg().Scope() || (scope.Contains(id.Pos()) && id.Pos() > obj.Pos()) {
+			return fmt.Errorf("aster: %s would shadow the reference to %s at %s",
+				newName, use, fa.pkg.prog.fset.Position(id.Pos()))
+		}
+	}
+	return nil
+}
+
+// renameShadowed checks whether the reference id would be shadowed by an inner declaration of the new name.
+func (fa *facade) renameShadowed(id *ast.Ident, newName string) error {
+	scope := fa.pkg.Pkg.Scope().Innermost(id.Pos())
+	if scope == nil {
+		return nil
+	}
+	_, o := scope.LookupParent(newName, id.Pos())
+	if o != nil && o.Parent() != nil && isInnerScope(o.Parent(), fa.obj.Parent()) {
+		return fmt.Errorf("aster: the reference to %s at %s would be shadowed by %s",
+			fa.Name(), fa.pkg.prog.fset.Position(id.Pos()), o)
+	}
+	return nil
+}
+
+// isInnerScope reports whether inner is nested in outer strictly.
+func isInnerScope(inner, outer *types.Scope) bool {
+	for s := inner.Parent(); s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedTypeName returns the type name of the embedded field type T or *T.
+func embeddedTypeName(typ types.Type) *types.TypeName {
+	if p, ok := typ.(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+	if t, ok := types.Unalias(typ).(*types.Named); ok {
+		return t.Obj()
+	}
+	return nil
+}
+
+// InlineFunc replaces the calls of the trivial function or method fa in its package
+// with its return expression, in which the parameters are substituted by the arguments,
+// and returns the number of the inlined calls.
+// The function is removed if it is unexported and no longer referenced.
+// NOTE:
+//  Return error, if the body of fa is not a single return of one expression
+//  built of identifiers, literals, selectors, index, unary and binary expressions,
+//  or if the receiver is used other than to select a field;
+//  An untyped constant argument is converted to the type of its parameter,
+//  and the expression to the result type, if its type on its own differs,
+//  such as `error(e)` for `func asErr(e *E) error { return e }`;
+//  A call is left untouched, if an argument is not an identifier, literal or selector,
+//  or if its type is not identical to the type of its parameter,
+//  or if a type to convert to is not predeclared or declared in the package, or is shadowed at the call,
+//  or if an identifier of the expression would resolve to another object at the call;
+//  It only edits the AST, the type-checker deductions are not updated.
+func (prog *Program) InlineFunc(fa Facade) (int, error) {
+	f, ok := fa.(*facade)
+	if !ok || f.ObjKind() != Fun {
+		return 0, fmt.Errorf("aster: %s is not a function", fa.Name())
+	}
+	obj := f.obj.(*types.Func)
+	sig := obj.Type().(*types.Signature)
+	decl := f.funcDecl()
+	if decl == nil || decl.Body == nil || sig.Variadic() ||
+		sig.TypeParams().Len() > 0 || sig.RecvTypeParams().Len() > 0 {
+		return 0, fmt.Errorf("aster: can not inline %s", f.Name())
+	}
+	var ret *ast.ReturnStmt
+	if len(decl.Body.List) == 1 {
+		ret, _ = decl.Body.List[0].(*ast.ReturnStmt)
+	}
+	if ret == nil || len(ret.Results) != 1 {
+		return 0, fmt.Errorf("aster: %s is not a single return of one expression", f.Name())
+	}
+	p := f.pkg
+	expr := ret.Results[0]
+	var recv types.Object
+	if sig.Recv() != nil {
+		recv = sig.Recv()
+	}
+	if !p.inlinable(expr, recv) {
+		return 0, fmt.Errorf("aster: %s is not trivial to inline", f.Name())
+	}
+	result := sig.Results().At(0).Type()
+	params := make(map[types.Object]int, sig.Params().Len())
+	for i := 0; i < sig.Params().Len(); i++ {
+		params[sig.Params().At(i)] = i
+	}
+	var count int
+	inlined := make(map[*ast.Ident]bool)
+	for _, file := range p.files {
+		astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+			call, ok := c.Node().(*ast.CallExpr)
+			if !ok || len(call.Args) != len(params) || call.Ellipsis.IsValid() {
+				return true
+			}
+			var id *ast.Ident
+			var recvArg ast.Expr
+			switch fun := ast.Unparen(call.Fun).(type) {
+			case *ast.Ident:
+				if recv == nil {
+					id = fun
+				}
+			case *ast.SelectorExpr:
+				sel := p.info.Selections[fun]
+				if recv == nil && sel == nil {
+					id = fun.Sel
+				} else if recv != nil && sel != nil && sel.Kind() == types.MethodVal && len(sel.Index()) == 1 {
+					id, recvArg = fun.Sel, fun.X
+				}
+			}
+			if id == nil || p.info.Uses[id] != obj || (recv != nil && !isSimpleOperand(recvArg)) {
+				return true
+			}
+			convs := make([]*types.TypeName, len(call.Args))
+			for i, arg := range call.Args {
+				typ := sig.Params().At(i).Type()
+				if !isSimpleOperand(arg) {
+					return true
+				}
+				if p.untypedType(arg) != nil {
+					if convs[i] = p.typeNameAt(typ, call.Pos()); convs[i] == nil {
+						return true
+					}
+				} else if t := p.info.TypeOf(arg); t == nil || !types.Identical(t, typ) {
+					return true
+				}
+			}
+			var resultConv *types.TypeName
+			if t := p.naturalType(expr); t == nil || !types.Identical(t, result) {
+				if resultConv = p.typeNameAt(result, call.Pos()); resultConv == nil {
+					return true
+				}
+			}
+			if !p.resolvesAlike(expr, call.Pos(), params, recv) {
+				return true
+			}
+			pos := call.Pos()
+			var repl ast.Expr = p.cloneInlined(expr, pos, func(id *ast.Ident) ast.Expr {
+				use := p.info.Uses[id]
+				if recv != nil && use == recv {
+					return p.cloneInlined(recvArg, pos, nil)
+				}
+				if i, ok := params[use]; ok {
+					arg := p.cloneInlined(call.Args[i], pos, nil)
+					if convs[i] != nil {
+						arg = p.conversion(convs[i], arg, pos)
+					}
+					return arg
+				}
+				return nil
+			})
+			if resultConv != nil {
+				repl = p.conversion(resultConv, repl, pos)
+			}
+			if needsParens(repl, c.Parent(), c.Name()) {
+				repl = &ast.ParenExpr{Lparen: pos, X: repl, Rparen: pos}
+			}
+			c.Replace(repl)
+			inlined[id] = true
+			count++
+			return true
+		})
+	}
+	if count > 0 && recv == nil && !obj.Exported() && !p.referencedExcept(obj, inlined) {
+		p.removeFuncDecl(decl, expr)
+		p.removeFacade(f.ident)
+	}
+	return count, nil
+}
+
+// inlinable reports whether expr is free of calls and side effects,
+// and uses the receiver recv, if any, only to select a field.
+func (p *PackageInfo) inlinable(expr ast.Expr, recv types.Object) bool {
+	ok := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case nil, *ast.BasicLit, *ast.ParenExpr, *ast.IndexExpr, *ast.BinaryExpr, *ast.StarExpr:
+		case *ast.Ident:
+			ok = recv == nil || p.info.Uses[x] != recv
+		case *ast.SelectorExpr:
+			if id, isIdent := ast.Unparen(x.X).(*ast.Ident); isIdent && recv != nil && p.info.Uses[id] == recv {
+				sel := p.info.Selections[x]
+				ok = sel != nil && sel.Kind() == types.FieldVal
+				return false
+			}
+		case *ast.UnaryExpr:
+			ok = x.Op != token.ARROW
+		default:
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// resolvesAlike reports whether the identifiers of expr, other than the parameters,
+// the receiver and the selected names, resolve to the same objects at pos.
+func (p *PackageInfo) resolvesAlike(expr ast.Expr, pos token.Pos, params map[types.Object]int, recv types.Object) bool {
+	scope := p.Pkg.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	ok := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(x.X, func(n ast.Node) bool {
+				if id, isIdent := n.(*ast.Ident); isIdent && ok {
+					ok = p.resolvesAlikeIdent(id, scope, pos, params, recv)
+				}
+				return ok
+			})
+			return false
+		case *ast.Ident:
+			ok = p.resolvesAlikeIdent(x, scope, pos, params, recv)
+		}
+		return ok
+	})
+	return ok
+}
+
+func (p *PackageInfo) resolvesAlikeIdent(id *ast.Ident, scope *types.Scope, pos token.Pos, params map[types.Object]int, recv types.Object) bool {
+	use := p.info.Uses[id]
+	if _, ok := params[use]; ok || use == nil || use == recv {
+		return true
+	}
+	_, o := scope.LookupParent(id.Name, pos)
+	if pkgName, ok := use.(*types.PkgName); ok {
+		o, ok := o.(*types.PkgName)
+		return ok && o.Imported() == pkgName.Imported()
+	}
+	return o == use
+}
+
+// cloneInlined returns a copy of the inlinable expr at pos,
+// with the identifiers replaced by the non-nil results of subst.
+// The copied identifiers keep the objects they use.
+func (p *PackageInfo) cloneInlined(expr ast.Expr, pos token.Pos, subst func(*ast.Ident) ast.Expr) ast.Expr {
+	clone := func(e ast.Expr) ast.Expr { return p.cloneInlined(e, pos, subst) }
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if subst != nil {
+			if e := subst(x); e != nil {
+				return e
+			}
+		}
+		id := &ast.Ident{NamePos: pos, Name: x.Name}
+		if use := p.info.Uses[x]; use != nil {
+			p.info.Uses[id] = use
+		}
+		return id
+	case *ast.BasicLit:
+		return &ast.BasicLit{ValuePos: pos, Kind: x.Kind, Value: x.Value}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{Lparen: pos, X: clone(x.X), Rparen: pos}
+	case *ast.SelectorExpr:
+		sel := &ast.Ident{NamePos: pos, Name: x.Sel.Name}
+		if use := p.info.Uses[x.Sel]; use != nil {
+			p.info.Uses[sel] = use
+		}
+		return &ast.SelectorExpr{X: clone(x.X), Sel: sel}
+	case *ast.IndexExpr:
+		return &ast.IndexExpr{X: clone(x.X), Lbrack: pos, Index: clone(x.Index), Rbrack: pos}
+	case *ast.StarExpr:
+		return &ast.StarExpr{Star: pos, X: clone(x.X)}
+	case *ast.UnaryExpr:
+		return &ast.UnaryExpr{OpPos: pos, Op: x.Op, X: clone(x.X)}
+	case *ast.BinaryExpr:
+		return &ast.BinaryExpr{X: clone(x.X), OpPos: pos, Op: x.Op, Y: clone(x.Y)}
+	}
+	return expr
+}
+
+// isSimpleOperand reports whether expr is an identifier, a literal or a selector of them,
+// which can be duplicated or dropped without changing the behavior.
+func isSimpleOperand(expr ast.Expr) bool {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return isSimpleOperand(x.X)
+	}
+	return false
+}
+
+// untypedType returns the untyped type of expr, such as untyped int for a literal
+// or untyped bool for a comparison, or nil if it is typed.
+// NOTE: The recorded type and value of an untyped expression are converted by its context.
+func (p *PackageInfo) untypedType(expr ast.Expr) *types.Basic {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT:
+			return types.Typ[types.UntypedInt]
+		case token.FLOAT:
+			return types.Typ[types.UntypedFloat]
+		case token.IMAG:
+			return types.Typ[types.UntypedComplex]
+		case token.CHAR:
+			return types.Typ[types.UntypedRune]
+		case token.STRING:
+			return types.Typ[types.UntypedString]
+		}
+	case *ast.Ident:
+		return p.untypedObject(x)
+	case *ast.SelectorExpr:
+		return p.untypedObject(x.Sel)
+	case *ast.UnaryExpr:
+		if x.Op != token.AND && x.Op != token.ARROW {
+			return p.untypedType(x.X)
+		}
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return types.Typ[types.UntypedBool]
+		case token.SHL, token.SHR:
+			return p.untypedType(x.X) // such as 1 << n
+		}
+		a, b := p.untypedType(x.X), p.untypedType(x.Y)
+		if a == nil || b == nil {
+			return nil
+		}
+		if b.Kind() > a.Kind() {
+			return b // such as untyped float for 1 + 0.5
+		}
+		return a
+	}
+	return nil
+}
+
+// untypedObject returns the untyped type of the constant or nil used by id, or nil if it is typed.
+func (p *PackageInfo) untypedObject(id *ast.Ident) *types.Basic {
+	switch obj := p.info.Uses[id].(type) {
+	case *types.Nil:
+		return types.Typ[types.UntypedNil]
+	case *types.Const:
+		if b, ok := obj.Type().(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+			return b
+		}
+	}
+	return nil
+}
+
+// naturalType returns the type of expr on its own, which is the default type of an untyped expr,
+// such as int for the literal 1 returned by a function of result float64.
+func (p *PackageInfo) naturalType(expr ast.Expr) types.Type {
+	if b := p.untypedType(expr); b != nil {
+		return types.Default(b)
+	}
+	return p.info.TypeOf(expr)
+}
+
+// typeNameAt returns the name of typ, which is predeclared or declared in the package,
+// if it is not shadowed at pos.
+func (p *PackageInfo) typeNameAt(typ types.Type, pos token.Pos) *types.TypeName {
+	var obj *types.TypeName
+	switch t := typ.(type) {
+	case *types.Basic:
+		obj, _ = types.Universe.Lookup(t.Name()).(*types.TypeName)
+	case *types.Named:
+		if t.TypeArgs().Len() == 0 {
+			obj = t.Obj()
+		}
+	case *types.Alias:
+		if t.TypeArgs().Len() == 0 {
+			obj = t.Obj()
+		}
+	}
+	if obj == nil || !types.Identical(obj.Type(), typ) || (obj.Pkg() != nil && obj.Pkg() != p.Pkg) {
+		return nil
+	}
+	scope := p.Pkg.Scope().Innermost(pos)
+	if scope == nil {
+		return nil
+	}
+	if _, o := scope.LookupParent(obj.Name(), pos); o != obj {
+		return nil
+	}
+	return obj
+}
+
+// conversion returns the conversion of expr to the type named by tn at pos.
+func (p *PackageInfo) conversion(tn *types.TypeName, expr ast.Expr, pos token.Pos) ast.Expr {
+	fun := &ast.Ident{NamePos: pos, Name: tn.Name()}
+	p.info.Uses[fun] = tn
+	return &ast.CallExpr{Fun: fun, Lparen: pos, Args: []ast.Expr{expr}, Rparen: pos}
+}
+
+// needsParens reports whether the inlined expr must be parenthesized
+// as the field name of the parent node.
+func needsParens(expr ast.Expr, parent ast.Node, name string) bool {
+	switch expr.(type) {
+	case *ast.UnaryExpr, *ast.BinaryExpr, *ast.StarExpr:
+	default:
+		return false
+	}
+	switch parent.(type) {
+	case *ast.CallExpr:
+		return name != "Args"
+	case *ast.KeyValueExpr, *ast.CompositeLit, *ast.IndexExpr:
+		return false
+	case ast.Expr:
+		return true
+	}
+	return false
+}
+
+// referencedExcept reports whether obj is referenced by an identifier of the package out of the except set.
+func (p *PackageInfo) referencedExcept(obj types.Object, except map[*ast.Ident]bool) bool {
+	for id, use := range p.info.Uses {
+		if use == obj && !except[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFuncDecl removes the function declaration and its comments from the file,
+// and then the imports that were used only by the expression of its body.
+func (p *PackageInfo) removeFuncDecl(decl *ast.FuncDecl, expr ast.Expr) {
+	file := p.fileOf(decl.Pos())
+	if file == nil {
+		return
+	}
+	for i, d := range file.Decls {
+		if d == decl {
+			file.Decls = append(file.Decls[:i:i], file.Decls[i+1:]...)
+			break
+		}
+	}
+	var groups []*ast.CommentGroup
+	for _, g := range file.Comments {
+		if g == decl.Doc || (g.Pos() >= decl.Pos() && g.End() <= decl.End()) {
+			groups = append(groups, g)
+		}
+	}
+	p.removeComments(groups...)
+	paths := make(map[string]bool)
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if pkgName, ok := p.info.Uses[id].(*types.PkgName); ok {
+				paths[pkgName.Imported().Path()] = true
+			}
+		}
+		return true
+	})
+	p.removeUnusedImports(file, paths)
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+func TestRename(t *testing.T) {
+	var files = map[string]string{
+		"a.go": "package rn\n\n// T is a thing.\ntype T struct{ N int }\n\nvar V = T{N: 1}\n",
+		"b.go": `package rn
+
+type Box struct {
+	*T
+	Size int
+}
+
+func use(b Box) int {
+	x := len("x")
+	var t T = *b.T
+	return t.N + V.N + x
+}
+`,
+	}
+	srcDir := "../_out/rename"
+	if err := os.MkdirAll(srcDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog, err := aster.NewProgram().Import(srcDir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.InitialPackages()[0]
+	typ := pkg.Lookup(aster.Typ, 0, "T")[0]
+	v := pkg.Lookup(aster.Var, 0, "V")[0]
+	for _, name := range []string{"Box", "x", "len", "1V"} {
+		if err = prog.Rename(v, name); err == nil {
+			t.Fatalf("Rename(V, %s): want error", name)
+		}
+	}
+	if err = prog.Rename(typ, "Item"); err != nil {
+		t.Fatal(err)
+	}
+	if err = prog.Rename(v, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	if typ.Name() != "Item" || v.Name() != "Default" {
+		t.Fatalf("Rename: got %s, %s", typ.Name(), v.Name())
+	}
+	var code string
+	for _, f := range pkg.Files() {
+		c, err := pkg.FormatNode(f.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code += c
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"type Item struct{ N int }",
+		"var Default = Item{N: 1}",
+		"\t*Item\n",
+		"var t Item = *b.Item",
+		"return t.N + Default.N + x",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("Rename: want %q in code", want)
+		}
+	}
+	if errs := pkg.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+}
+
+func TestInlineFunc(t *testing.T) {
+	var src = `package test
+
+import "math"
+
+type P struct{ name string }
+
+// Name returns the name.
+func (p *P) Name() string { return p.name }
+
+// twice doubles n.
+func twice(n int) int {
+	return n + n // no overflow check
+}
+
+func bound(n int) bool { return n < math.MaxInt8 }
+
+func half(n float64) float64 { return n / 2 }
+
+func isNil(v interface{}) bool { return v == nil }
+
+func complex(n int) int {
+	if n > 0 {
+		return n
+	}
+	return -n
+}
+
+func use(p *P, v P, n int) (string, int, int, bool, int) {
+	_ = twice(n) * 3
+	return p.Name() + v.Name(), twice(2), complex(n), bound(n), len([]int{twice(n)})
+}
+
+func conv(p *P, f float64) (float64, float64, bool, bool) {
+	return half(3), half(f), isNil(p), isNil(nil)
+}
+
+type E struct{}
+
+func (*E) Error() string { return "E" }
+
+func asErr(e *E) error { return e }
+
+func one() float64 { return 1 }
+
+func result(e *E) (bool, float64) {
+	x := one()
+	return asErr(e) != nil, x / 2
+}
+`
+	prog, err := aster.LoadFile("../_out/inline_func.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.InitialPackages()[0]
+	name := pkg.LookupFuncs(true, "Name")[0]
+	twice := pkg.LookupFuncs(false, "twice")[0]
+	bound := pkg.LookupFuncs(false, "bound")[0]
+	half := pkg.LookupFuncs(false, "half")[0]
+	isNil := pkg.LookupFuncs(false, "isNil")[0]
+	asErr := pkg.LookupFuncs(false, "asErr")[0]
+	one := pkg.LookupFuncs(false, "one")[0]
+	cplx := pkg.LookupFuncs(false, "complex")[0]
+	if _, err = prog.InlineFunc(cplx); err == nil {
+		t.Fatal("InlineFunc(complex): want error")
+	}
+	for _, c := range []struct {
+		fa    aster.Facade
+		count int
+	}{{name, 2}, {twice, 3}, {bound, 1}, {half, 2}, {isNil, 0}, {asErr, 1}, {one, 1}} {
+		n, err := prog.InlineFunc(c.fa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != c.count {
+			t.Fatalf("InlineFunc(%s): want %d calls, got %d", c.fa.Name(), c.count, n)
+		}
+	}
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"func (p *P) Name() string { return p.name }",
+		"func complex(n int) int {",
+		"_ = (n + n) * 3",
+		"return p.name + v.name, int(2) + int(2), complex(n), n < math.MaxInt8, len([]int{n + n})",
+		"return float64(3) / 2, f / 2, isNil(p), isNil(nil)",
+		"func isNil(v interface{}) bool { return v == nil }",
+		"x := float64(1)",
+		"return error(e) != nil, x / 2",
+		`import "math"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("InlineFunc: want %q in code", want)
+		}
+	}
+	for _, unwanted := range []string{"twice", "bound", "overflow", "half", "asErr", "one()"} {
+		if strings.Contains(code, unwanted) {
+			t.Fatalf("InlineFunc: want no %q in code", unwanted)
+		}
+	}
+	if errs := pkg.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+}
@@ -16,7 +16,14 @@ package aster
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 // ---------------------------------- TypKind = Signature (function) ----------------------------------
@@ -67,3 +74,830 @@ func (fa *facade) Results() *types.Tuple {
 func (fa *facade) Variadic() bool {
 	return fa.signature().Variadic()
 }
+
+// ResultType returns the results of the signature as a tuple,
+// whose type expressions are those of the function or interface method declaration.
+// NOTE: Panic, if TypKind != Signature
+func (fa *facade) ResultType() *TupleType {
+	tuple := fa.signature().Results()
+	var list *ast.FieldList
+	if ft := fa.funcType(); ft != nil {
+		list = ft.Results
+	}
+	return newTupleType(tuple, list)
+}
+
+// NamedResult is a named result variable of a signature.
+type NamedResult struct {
+	Name string
+	Type TypeNode
+}
+
+// NamedResults returns the named results of the signature, such as n and err of `(n int, err error)`,
+// with the type expressions of the declaration.
+// NOTE: Return nil, if TypKind != Signature or the results are unnamed
+func (fa *facade) NamedResults() []NamedResult {
+	if fa.TypKind() != Signature {
+		return nil
+	}
+	results := fa.ResultType()
+	if results.Len() == 0 || results.Tuple().At(0).Name() == "" {
+		return nil
+	}
+	list := make([]NamedResult, results.Len())
+	for i := range list {
+		list[i].Name = results.Tuple().At(i).Name()
+		list[i].Type, _ = results.At(i)
+	}
+	return list
+}
+
+// funcType returns the type of the function or interface method declaration, or nil.
+func (fa *facade) funcType() *ast.FuncType {
+	nodes, _ := fa.pkg.pathEnclosingInterval(fa.ident.Pos(), fa.ident.End())
+	for _, n := range nodes {
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			if x.Name == fa.ident {
+				return x.Type
+			}
+		case *ast.Field:
+			if ft, ok := x.Type.(*ast.FuncType); ok && len(x.Names) == 1 && x.Names[0] == fa.ident {
+				return ft
+			}
+		}
+	}
+	return nil
+}
+
+// TupleType is an ordered list of the variables of a signature, such as the results `(int, error)`.
+type TupleType struct {
+	tuple *types.Tuple
+	exprs []ast.Expr // the type expressions per variable, or nil if not declared in the source
+}
+
+func newTupleType(tuple *types.Tuple, list *ast.FieldList) *TupleType {
+	t := &TupleType{tuple: tuple}
+	if list != nil && list.NumFields() == tuple.Len() {
+		for _, field := range list.List {
+			for n := max(len(field.Names), 1); n > 0; n-- {
+				t.exprs = append(t.exprs, field.Type)
+			}
+		}
+	}
+	return t
+}
+
+// TypKind returns Tuple.
+func (t *TupleType) TypKind() TypKind { return Tuple }
+
+// Tuple returns the types.Tuple.
+func (t *TupleType) Tuple() *types.Tuple { return t.tuple }
+
+// Len returns the number of variables of the tuple.
+func (t *TupleType) Len() int { return t.tuple.Len() }
+
+// At returns the type of the i'th variable of the tuple, with its type expression if declared in the source.
+// NOTE: Return false, if i is not in the range [0, Len()).
+func (t *TupleType) At(i int) (TypeNode, bool) {
+	if i < 0 || i >= t.tuple.Len() {
+		return TypeNode{}, false
+	}
+	node := TypeNode{Type: t.tuple.At(i).Type()}
+	if t.exprs != nil {
+		node.Node = t.exprs[i]
+	}
+	return node, true
+}
+
+// AsInterfaceMethod returns the interface method field of the function or method,
+// which has the same name and signature without receiver.
+// NOTE: Return error, if ObjKind != Fun
+func (fa *facade) AsInterfaceMethod() (*ast.Field, error) {
+	if fa.ObjKind() != Fun {
+		return nil, fmt.Errorf("aster: AsInterfaceMethod of non-Fun ObjKind: %s", fa.ObjKind())
+	}
+	expr, err := parser.ParseExpr(types.TypeString(fa.signature(), fa.nameQualifier()))
+	if err != nil {
+		return nil, err
+	}
+	resetPos(expr, fa.ident.Pos())
+	return &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent(fa.Name())},
+		Type:  expr.(*ast.FuncType),
+	}, nil
+}
+
+// HasContextFirst reports whether the first parameter of the function or method is of type context.Context.
+// NOTE: Return false, if TypKind != Signature
+func (fa *facade) HasContextFirst() bool {
+	if fa.TypKind() != Signature {
+		return false
+	}
+	params := fa.signature().Params()
+	return params.Len() > 0 && isNamed(params.At(0).Type(), "context", "Context")
+}
+
+// ioPackages are the packages whose functions and methods do I/O.
+var ioPackages = map[string]bool{
+	"database/sql": true,
+	"io":           true,
+	"io/ioutil":    true,
+	"net":          true,
+	"net/http":     true,
+	"os":           true,
+	"os/exec":      true,
+}
+
+// MissingContext returns the exported functions and methods in the initial packages,
+// in the order of package path and declaration position,
+// which do I/O-ish work without taking a context.Context as the first parameter.
+// NOTE:
+//  It is a heuristic: a function does I/O-ish work if its body calls a function or method
+//  which takes a context.Context as the first parameter, or which is declared in a package
+//  such as io, os, net, net/http or database/sql.
+func (prog *Program) MissingContext() []Facade {
+	list := prog.Lookup(Fun, Signature, "")
+	SortFacades(list, SortByPackage, SortByPosition)
+	var missing []Facade
+	for _, fa := range list {
+		f := fa.(*facade)
+		if f.Exported() && !f.HasContextFirst() && f.doesIO() {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// doesIO reports whether the function body calls a function or method of an I/O package,
+// or which takes a context.Context as the first parameter.
+func (fa *facade) doesIO() bool {
+	body := fa.funcBody()
+	if body == nil {
+		return false
+	}
+	var found bool
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return !found
+		}
+		var id *ast.Ident
+		switch fn := ast.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = fn
+		case *ast.SelectorExpr:
+			id = fn.Sel
+		}
+		if fn, ok := fa.pkg.info.Uses[id].(*types.Func); ok {
+			params := fn.Type().(*types.Signature).Params()
+			found = (fn.Pkg() != nil && ioPackages[fn.Pkg().Path()]) ||
+				(params.Len() > 0 && isNamed(params.At(0).Type(), "context", "Context"))
+		}
+		return !found
+	})
+	return found
+}
+
+// uncheckedErrorFuncs are the functions and methods whose error results may be unchecked,
+// keyed by the full names.
+var uncheckedErrorFuncs = map[string]bool{
+	"fmt.Print":                      true,
+	"fmt.Printf":                     true,
+	"fmt.Println":                    true,
+	"(*bytes.Buffer).Write":          true,
+	"(*bytes.Buffer).WriteByte":      true,
+	"(*bytes.Buffer).WriteRune":      true,
+	"(*bytes.Buffer).WriteString":    true,
+	"(*strings.Builder).Write":       true,
+	"(*strings.Builder).WriteByte":   true,
+	"(*strings.Builder).WriteRune":   true,
+	"(*strings.Builder).WriteString": true,
+}
+
+// UncheckedErrors returns the positions of the calls in the initial packages,
+// whose error results are discarded by an expression statement or by the blank identifier
+// of an assignment, such as `f.Close()`, `_ = f.Close()` and `n, _ := w.Write(p)`.
+// The calls of fmt.Print, fmt.Printf, fmt.Println and the write methods of
+// bytes.Buffer and strings.Builder are excluded.
+// NOTE: The calls in the go and defer statements are not reported.
+func (prog *Program) UncheckedErrors() []token.Position {
+	var list []token.Position
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			for _, call := range pkg.uncheckedErrorCalls(f) {
+				list = append(list, prog.fset.Position(call.Pos()))
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return list
+}
+
+// uncheckedErrorCalls returns the calls of the file node whose error results are discarded.
+func (p *PackageInfo) uncheckedErrorCalls(node ast.Node) []*ast.CallExpr {
+	var list []*ast.CallExpr
+	// check reports the call, if its results at the discarded indexes include an error.
+	check := func(expr ast.Expr, discarded func(i int) bool) {
+		call, ok := ast.Unparen(expr).(*ast.CallExpr)
+		if !ok || p.info.Types[call.Fun].IsType() || p.errorsUncheckable(call) {
+			return
+		}
+		var results []types.Type
+		switch t := p.info.TypeOf(call).(type) {
+		case nil:
+		case *types.Tuple:
+			for i := 0; i < t.Len(); i++ {
+				results = append(results, t.At(i).Type())
+			}
+		default:
+			results = append(results, t)
+		}
+		for i, t := range results {
+			if discarded(i) && isNamed(t, "", "error") {
+				list = append(list, call)
+				return
+			}
+		}
+	}
+	isBlank := func(e ast.Expr) bool {
+		id, ok := ast.Unparen(e).(*ast.Ident)
+		return ok && id.Name == "_"
+	}
+	assign := func(lhs []ast.Expr, rhs []ast.Expr) {
+		if len(rhs) == 1 && len(lhs) > 1 {
+			check(rhs[0], func(i int) bool { return i < len(lhs) && isBlank(lhs[i]) })
+			return
+		}
+		for i := range rhs {
+			if i < len(lhs) && isBlank(lhs[i]) {
+				check(rhs[i], func(int) bool { return true })
+			}
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ExprStmt:
+			check(x.X, func(int) bool { return true })
+		case *ast.AssignStmt:
+			if x.Tok == token.ASSIGN || x.Tok == token.DEFINE {
+				assign(x.Lhs, x.Rhs)
+			}
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(x.Names))
+			for i, name := range x.Names {
+				lhs[i] = name
+			}
+			assign(lhs, x.Values)
+		}
+		return true
+	})
+	return list
+}
+
+// errorsUncheckable reports whether the call is of a function or method in uncheckedErrorFuncs.
+func (p *PackageInfo) errorsUncheckable(call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fn := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fn
+	case *ast.SelectorExpr:
+		id = fn.Sel
+	default:
+		return false
+	}
+	fn, ok := p.info.Uses[id].(*types.Func)
+	return ok && uncheckedErrorFuncs[fn.Origin().FullName()]
+}
+
+// LeakyAPIs returns the exported functions and methods of exported types in the initial packages,
+// whose parameter or result types reference the unexported defined types of the same package.
+func (prog *Program) LeakyAPIs() []Facade {
+	var list []Facade
+	for _, pkg := range prog.InitialPackages() {
+		for _, fa := range pkg.facades {
+			if fa.ObjKind() != Fun || !fa.Exported() {
+				continue
+			}
+			sig := fa.signature()
+			if recv := sig.Recv(); recv != nil {
+				typ := recv.Type()
+				if ptr, ok := typ.(*types.Pointer); ok {
+					typ = ptr.Elem()
+				}
+				if t, ok := types.Unalias(typ).(*types.Named); !ok || !t.Obj().Exported() {
+					continue
+				}
+			}
+			if refersUnexported(sig.Params(), pkg.Pkg) || refersUnexported(sig.Results(), pkg.Pkg) {
+				list = append(list, fa)
+			}
+		}
+	}
+	return list
+}
+
+// refersUnexported reports whether typ references the unexported defined types of pkg,
+// without descending into the other defined types.
+func refersUnexported(typ types.Type, pkg *types.Package) bool {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Named:
+		if t.Obj().Pkg() == pkg && !t.Obj().Exported() {
+			return true
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if refersUnexported(t.TypeArgs().At(i), pkg) {
+				return true
+			}
+		}
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if refersUnexported(t.At(i).Type(), pkg) {
+				return true
+			}
+		}
+	case *types.Pointer:
+		return refersUnexported(t.Elem(), pkg)
+	case *types.Slice:
+		return refersUnexported(t.Elem(), pkg)
+	case *types.Array:
+		return refersUnexported(t.Elem(), pkg)
+	case *types.Chan:
+		return refersUnexported(t.Elem(), pkg)
+	case *types.Map:
+		return refersUnexported(t.Key(), pkg) || refersUnexported(t.Elem(), pkg)
+	case *types.Signature:
+		return refersUnexported(t.Params(), pkg) || refersUnexported(t.Results(), pkg)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if refersUnexported(t.Field(i).Type(), pkg) {
+				return true
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			if refersUnexported(t.ExplicitMethod(i).Type(), pkg) {
+				return true
+			}
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if refersUnexported(t.EmbeddedType(i), pkg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReceiverPointer changes the receiver type of the method to *T if ptr, or to T if not.
+// The change is refused, if it would change the behavior of the method body:
+// writing to the receiver or its fields and elements, taking their address, calling their pointer methods,
+// or using the receiver other than to select, index or dereference it, such as passing it on.
+// NOTE:
+//  Return error, if it is not a method declared with a receiver of the defined type;
+//  It only edits the AST, the type-checker deductions are not updated.
+func (fa *facade) SetReceiverPointer(ptr bool) error {
+	if !fa.IsMethod() {
+		return fmt.Errorf("aster: %s is not a method", fa.Name())
+	}
+	decl := fa.funcDecl()
+	if decl == nil || decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return fmt.Errorf("aster: can't find the receiver of method %s", fa.Name())
+	}
+	recv := decl.Recv.List[0]
+	star, isPtr := recv.Type.(*ast.StarExpr)
+	if isPtr == ptr {
+		return nil
+	}
+	if len(recv.Names) == 1 && decl.Body != nil {
+		obj := fa.pkg.info.Defs[recv.Names[0]]
+		if obj != nil && fa.pkg.usesMutably(decl.Body, obj) {
+			return fmt.Errorf("aster: the body of method %s mutates or passes on receiver %s",
+				fa.Name(), obj.Name())
+		}
+	}
+	if ptr {
+		recv.Type = &ast.StarExpr{Star: recv.Type.Pos(), X: recv.Type}
+	} else {
+		recv.Type = star.X
+	}
+	return nil
+}
+
+// HasRecover reports whether the body of the function or method calls the builtin recover,
+// including in the function literals, such as `defer func() { recover() }()`.
+// NOTE: Return false, if ObjKind != Fun or it has no body
+func (fa *facade) HasRecover() bool {
+	body := fa.funcBody()
+	if body == nil {
+		return false
+	}
+	recover := types.Universe.Lookup("recover")
+	var found bool
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok && fa.pkg.info.Uses[id] == recover {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// DeferredCalls returns the functions called by the defer statements of the body in source order,
+// such as "f.Close", "mu.Unlock" or "(func() literal)".
+// The defer statements in the function literals are excluded.
+// NOTE: Return nil, if ObjKind != Fun or it has no body
+func (fa *facade) DeferredCalls() []string {
+	body := fa.funcBody()
+	if body == nil {
+		return nil
+	}
+	var list []string
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			list = append(list, types.ExprString(x.Call.Fun))
+		}
+		return true
+	})
+	return list
+}
+
+// ReturnStatements returns the return statements of the function body in source order,
+// excluding those of the function literals.
+// NOTE: Return nil, if ObjKind != Fun or it has no body
+func (fa *facade) ReturnStatements() []*ast.ReturnStmt {
+	body := fa.funcBody()
+	if body == nil {
+		return nil
+	}
+	var list []*ast.ReturnStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			list = append(list, x)
+		}
+		return true
+	})
+	return list
+}
+
+// ErrorResultIndex returns the index of the last result of type error, such as 1 for `(int, error)`,
+// which is also the index of the error expression of a return statement listing all the results.
+// NOTE: Return -1, if TypKind != Signature or it has no error result
+func (fa *facade) ErrorResultIndex() int {
+	if fa.TypKind() != Signature {
+		return -1
+	}
+	results := fa.signature().Results()
+	for i := results.Len() - 1; i >= 0; i-- {
+		if isNamed(results.At(i).Type(), "", "error") {
+			return i
+		}
+	}
+	return -1
+}
+
+// NeverReturns reports whether every path of the function body ends in a call which never returns,
+// such as panic, os.Exit, log.Fatal and runtime.Goexit, in `select {}`,
+// or in an infinite for loop without break and return.
+// NOTE:
+//  It is a conservative heuristic by walking the body, the calls of other functions are not followed;
+//  Return false, if ObjKind != Fun or it has no body.
+func (fa *facade) NeverReturns() bool {
+	body := fa.funcBody()
+	if body == nil {
+		return false
+	}
+	return fa.pkg.stmtsNeverReturn(body.List)
+}
+
+// RenameParam renames the parameter of the function or method,
+// and its references in the body.
+// NOTE:
+//  Return error, if ObjKind != Fun, it has no body, or the parameter is not found;
+//  Return error, if newName is invalid, or collides with a parameter, result, receiver or local,
+//  or would shadow an outer object referenced in the body.
+func (fa *facade) RenameParam(oldName, newName string) error {
+	decl := fa.funcDecl()
+	if fa.ObjKind() != Fun || decl == nil || decl.Body == nil {
+		return fmt.Errorf("aster: %s is not a function with body", fa.Name())
+	}
+	if !isValidIdentifier(newName) {
+		return fmt.Errorf("aster: invalid identifier %q", newName)
+	}
+	info := fa.pkg.info
+	var param types.Object
+	var ident *ast.Ident
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			if name.Name == oldName {
+				param, ident = info.Defs[name], name
+			}
+		}
+	}
+	if param == nil {
+		return fmt.Errorf("aster: %s has no parameter %s", fa.Name(), oldName)
+	}
+	if oldName == newName {
+		return nil
+	}
+	scope := info.Scopes[decl.Type]
+	if scope == nil {
+		return fmt.Errorf("aster: can not rename the parameter of %s", fa.Name())
+	}
+	if err := localConflict(scope, newName); err != nil {
+		return err
+	}
+	var uses []*ast.Ident
+	for id, use := range info.Uses {
+		if id.Pos() < decl.Body.Pos() || id.End() > decl.Body.End() {
+			continue
+		}
+		if use == param {
+			uses = append(uses, id)
+		} else if use.Name() == newName && use.Parent() != nil {
+			// not a field or method, but an outer object since no local declares newName
+			return fmt.Errorf("aster: %s would shadow the reference to %s at %s",
+				newName, use, fa.pkg.prog.fset.Position(id.Pos()))
+		}
+	}
+	ident.Name = newName
+	for _, id := range uses {
+		id.Name = newName
+	}
+	return nil
+}
+
+// localConflict returns error, if the scope or its inner scopes declare name.
+func localConflict(scope *types.Scope, name string) error {
+	if o := scope.Lookup(name); o != nil {
+		return fmt.Errorf("aster: %s conflicts with %s", name, o)
+	}
+	for i := 0; i < scope.NumChildren(); i++ {
+		if err := localConflict(scope.Child(i), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noReturnFuncs are the functions which never return, keyed by package path and name.
+var noReturnFuncs = map[string]bool{
+	"os.Exit":        true,
+	"log.Fatal":      true,
+	"log.Fatalf":     true,
+	"log.Fatalln":    true,
+	"log.Panic":      true,
+	"log.Panicf":     true,
+	"log.Panicln":    true,
+	"runtime.Goexit": true,
+}
+
+// stmtsNeverReturn reports whether the statement list reaches a statement which never returns,
+// without any return statement before it.
+func (p *PackageInfo) stmtsNeverReturn(list []ast.Stmt) bool {
+	for _, stmt := range list {
+		if p.stmtNeverReturns(stmt) {
+			return true
+		}
+		if hasBranchOut(stmt, false) {
+			return false
+		}
+	}
+	return false
+}
+
+// stmtNeverReturns reports whether the control never passes the statement.
+func (p *PackageInfo) stmtNeverReturns(stmt ast.Stmt) bool {
+	switch x := stmt.(type) {
+	case *ast.ExprStmt:
+		call, ok := ast.Unparen(x.X).(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		var id *ast.Ident
+		switch fn := ast.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = fn
+		case *ast.SelectorExpr:
+			id = fn.Sel
+		}
+		switch obj := p.info.Uses[id].(type) {
+		case *types.Builtin:
+			return obj == types.Universe.Lookup("panic")
+		case *types.Func:
+			return obj.Pkg() != nil && noReturnFuncs[obj.Pkg().Path()+"."+obj.Name()]
+		}
+	case *ast.BlockStmt:
+		return p.stmtsNeverReturn(x.List)
+	case *ast.LabeledStmt:
+		return p.stmtNeverReturns(x.Stmt)
+	case *ast.IfStmt:
+		return x.Else != nil && p.stmtsNeverReturn(x.Body.List) && p.stmtNeverReturns(x.Else)
+	case *ast.ForStmt:
+		return x.Cond == nil && !hasBranchOut(x.Body, true)
+	case *ast.SelectStmt:
+		return len(x.Body.List) == 0
+	}
+	return false
+}
+
+// hasBranchOut reports whether the node contains a return or goto statement,
+// or a break statement if withBreak, outside the function literals.
+func hasBranchOut(node ast.Node, withBreak bool) bool {
+	var found bool
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			found = true
+		case *ast.BranchStmt:
+			found = found || x.Tok == token.GOTO || (withBreak && x.Tok == token.BREAK)
+		}
+		return !found
+	})
+	return found
+}
+
+// funcBody returns the body of the function or method, or nil.
+func (fa *facade) funcBody() *ast.BlockStmt {
+	if fa.ObjKind() != Fun {
+		return nil
+	}
+	if decl := fa.funcDecl(); decl != nil {
+		return decl.Body
+	}
+	return nil
+}
+
+// funcDecl returns the declaration of the function or method.
+func (fa *facade) funcDecl() *ast.FuncDecl {
+	nodes, _ := fa.pkg.pathEnclosingInterval(fa.ident.Pos(), fa.ident.End())
+	for _, n := range nodes {
+		if decl, ok := n.(*ast.FuncDecl); ok && decl.Name == fa.ident {
+			return decl
+		}
+	}
+	return nil
+}
+
+// usesMutably reports whether the node writes to the object or its fields and elements,
+// takes their address, calls their pointer methods, or uses the object other than
+// as the operand of a selector, index or dereference expression.
+func (p *PackageInfo) usesMutably(node ast.Node, obj types.Object) bool {
+	var found bool
+	isObj := func(expr ast.Expr) bool {
+		id := p.rootIdent(expr)
+		return id != nil && p.info.Uses[id] == obj
+	}
+	// isPart reports whether expr is the object or a field or an array element of it,
+	// which is reached without indirection other than of the object itself.
+	isPart := func(expr ast.Expr) bool {
+		for {
+			expr = ast.Unparen(expr)
+			if id, ok := expr.(*ast.Ident); ok {
+				return p.info.Uses[id] == obj
+			}
+			t := p.info.TypeOf(expr)
+			if t == nil {
+				return false
+			}
+			if _, ok := t.Underlying().(*types.Pointer); ok {
+				return false
+			}
+			switch x := expr.(type) {
+			case *ast.SelectorExpr:
+				expr = x.X
+			case *ast.IndexExpr:
+				if _, ok := p.info.TypeOf(x.X).Underlying().(*types.Array); !ok {
+					return false
+				}
+				expr = x.X
+			default:
+				return false
+			}
+		}
+	}
+	operands := make(map[*ast.Ident]bool)
+	markOperand := func(expr ast.Expr) {
+		if id, ok := ast.Unparen(expr).(*ast.Ident); ok {
+			operands[id] = true
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range x.Lhs {
+				found = found || (x.Tok != token.DEFINE && isObj(lhs))
+			}
+		case *ast.IncDecStmt:
+			found = found || isObj(x.X)
+		case *ast.UnaryExpr:
+			found = found || (x.Op == token.AND && isObj(x.X))
+		case *ast.SelectorExpr:
+			markOperand(x.X)
+			if sel := p.info.Selections[x]; sel != nil && sel.Kind() == types.MethodVal {
+				_, ptr := sel.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer)
+				found = found || (ptr && isPart(x.X))
+			}
+		case *ast.IndexExpr:
+			markOperand(x.X)
+		case *ast.StarExpr:
+			markOperand(x.X)
+		case *ast.Ident:
+			found = found || (p.info.Uses[x] == obj && !operands[x])
+		}
+		return !found
+	})
+	return found
+}
+
+// ReplaceTypeInSignatures replaces the parameter and result types identical to old
+// with new in the function and method declarations of the initial packages,
+// adding the imports needed by new, and returns the number of the replaced types.
+// The function bodies are not changed.
+// NOTE: It only edits the AST, the type-checker deductions are not updated.
+func (prog *Program) ReplaceTypeInSignatures(old, new types.Type) int {
+	var count int
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.files {
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				for _, list := range []*ast.FieldList{fn.Type.Params, fn.Type.Results} {
+					if list == nil {
+						continue
+					}
+					for _, field := range list.List {
+						typ := &field.Type
+						if e, ok := field.Type.(*ast.Ellipsis); ok {
+							typ = &e.Elt
+						}
+						if t := pkg.info.TypeOf(*typ); t == nil || !types.Identical(t, old) {
+							continue
+						}
+						expr, err := parser.ParseExpr(types.TypeString(new, pkg.importQualifier(f)))
+						if err != nil {
+							continue
+						}
+						shiftPos(expr, (*typ).Pos()-expr.Pos(), make(map[interface{}]bool))
+						*typ = expr
+						count++
+					}
+				}
+			}
+		}
+	}
+	return count
+}
+
+// importQualifier returns the qualifier of the type expressions in file f,
+// which adds the missing imports, named by an alias if the package name is taken.
+func (p *PackageInfo) importQualifier(f *ast.File) types.Qualifier {
+	return func(pkg *types.Package) string {
+		if pkg.Path() == p.Pkg.Path() {
+			return ""
+		}
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path != pkg.Path() {
+				continue
+			}
+			if spec.Name == nil {
+				return pkg.Name()
+			}
+			switch spec.Name.Name {
+			case "_":
+				continue
+			case ".":
+				return ""
+			}
+			return spec.Name.Name
+		}
+		name := pkg.Name()
+		for i := 2; p.nameTakenInFile(f, name); i++ {
+			name = pkg.Name() + strconv.Itoa(i)
+		}
+		if name == pkg.Name() {
+			astutil.AddImport(p.prog.fset, f, pkg.Path())
+		} else {
+			astutil.AddNamedImport(p.prog.fset, f, name, pkg.Path())
+		}
+		return name
+	}
+}
@@ -15,6 +15,11 @@
 package aster_test
 
 import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/henrylee2cn/aster/aster"
@@ -34,3 +39,487 @@ func(m *M)String()string{return "M"}
 		t.Logf("IsMethod:%v, Preview:%s", method.IsMethod(), method)
 	}
 }
+
+func TestAsInterfaceMethod(t *testing.T) {
+	var src = `package test
+import (
+	"io"
+	"net/url"
+)
+type M struct{}
+func(m *M)Get(key string)(string, bool){return "", false}
+func(m M)Write(w io.Writer, args ...interface{}) error{return nil}
+func(m M)Query(u *url.URL) url.Values{return nil}
+`
+	prog, err := aster.LoadFile("../_out/as_interface_method.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := prog.Lookup(aster.Typ, aster.Struct, "M")[0]
+	iface := &ast.InterfaceType{Methods: &ast.FieldList{}}
+	for i := 0; i < m.NumMethods(); i++ {
+		field, err := m.Method(i).AsInterfaceMethod()
+		if err != nil {
+			t.Fatal(err)
+		}
+		iface.Methods.List = append(iface.Methods.List, field)
+	}
+	code, err := prog.FormatNode(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `interface {
+	Get(key string) (string, bool)
+	Write(w io.Writer, args ...interface{}) error
+	Query(u *url.URL) url.Values
+}`
+	if code != want {
+		t.Fatalf("AsInterfaceMethod: want:\n%s\ngot:\n%s", want, code)
+	}
+	if _, err = m.AsInterfaceMethod(); err == nil {
+		t.Fatal("AsInterfaceMethod: want error for a type")
+	}
+}
+
+func TestLeakyAPIs(t *testing.T) {
+	var src = `package test
+type config struct{}
+type Server struct{}
+type client struct{}
+func New() *config { return nil }
+func NewServer(opts map[string][]config) *Server { return nil }
+func Clean(s *Server) error { return nil }
+func (s *Server) Clients() []client { return nil }
+func (c *client) Config() config { return config{} }
+func helper() config { return config{} }
+`
+	prog, err := aster.LoadFile("../_out/leaky_apis.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fa := range prog.LeakyAPIs() {
+		names = append(names, fa.Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "Clients,New,NewServer" {
+		t.Fatalf("LeakyAPIs: want Clients,New,NewServer, got %v", names)
+	}
+}
+
+func TestSetReceiverPointer(t *testing.T) {
+	var src = `package test
+import (
+	"fmt"
+	"sync"
+)
+type T struct{ n int }
+func (t T) Get() int { return t.n }
+func (t T) Inc() int { t.n++; return t.n }
+func (t *T) Set(n int) { t.n = n }
+func (t *T) Ptr() *int { return &t.n }
+type C struct {
+	mu   sync.Mutex
+	data *sync.Map
+	n    int
+}
+func (c *C) Len() int { c.mu.Lock(); defer c.mu.Unlock(); return c.n }
+func (c *C) Reset() int { c.reset(); return c.n }
+func (c *C) reset() { c.n = 0 }
+func (c *C) Print() { fmt.Println(c) }
+func (c *C) Load(k string) (interface{}, bool) { return c.data.Load(k) }
+`
+	prog, err := aster.LoadFile("../_out/set_receiver_pointer.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	method := func(name string) aster.Facade {
+		return prog.Lookup(aster.Fun, 0, name)[0]
+	}
+	if err = method("Get").SetReceiverPointer(true); err != nil {
+		t.Fatal(err)
+	}
+	if err = method("Get").SetReceiverPointer(true); err != nil {
+		t.Fatalf("SetReceiverPointer: want no-op for a pointer receiver, got %v", err)
+	}
+	if err = method("Inc").SetReceiverPointer(true); err == nil {
+		t.Fatal("SetReceiverPointer(Inc): want error for writing to the receiver")
+	}
+	if err = method("Ptr").SetReceiverPointer(false); err == nil {
+		t.Fatal("SetReceiverPointer(Ptr): want error for taking the address of the receiver")
+	}
+	for _, name := range []string{"Len", "Reset", "Print"} {
+		if err = method(name).SetReceiverPointer(false); err == nil {
+			t.Fatalf("SetReceiverPointer(%s): want error for calling a pointer method or passing on the receiver", name)
+		}
+	}
+	if err = method("Load").SetReceiverPointer(false); err != nil {
+		t.Fatal(err)
+	}
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func (t *T) Get() int", "func (t T) Inc() int", "func (t *T) Ptr() *int",
+		"func (c *C) Len() int", "func (c C) Load(k string) (interface{}, bool)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("SetReceiverPointer: want %q in code:\n%s", want, code)
+		}
+	}
+	if errs := pkg.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate: want no error, got %v", errs)
+	}
+}
+
+func TestReplaceTypeInSignatures(t *testing.T) {
+	prog, err := aster.NewProgram().
+		AddFile("../_out/replace_type_a.go", `package a
+func Find(name string, tags ...string) (string, error) { var s string; return s, nil }
+func Count(m map[string]int) int { return len(m) }
+`).
+		AddFile("../_out/replace_type_b.go", `package b
+import "time"
+var D time.Duration
+`).
+		Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	duration := prog.Package("time").Lookup(aster.Typ, 0, "Duration")[0]
+	n := prog.ReplaceTypeInSignatures(types.Typ[types.String], duration.Object().Type())
+	if n != 3 {
+		t.Fatalf("ReplaceTypeInSignatures: want 3, got %d", n)
+	}
+	pkg := prog.Package("a")
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `package a
+
+import "time"
+
+func Find(name time.Duration, tags ...time.Duration) (time.Duration, error) {
+	var s string
+	return s, nil
+}
+func Count(m map[string]int) int { return len(m) }
+`
+	if code != want {
+		t.Fatalf("ReplaceTypeInSignatures: want:\n%s\ngot:\n%s", want, code)
+	}
+}
+
+func TestDeferRecover(t *testing.T) {
+	var src = `package test
+import "sync"
+var mu sync.Mutex
+func Safe() (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			defer println("nested")
+		}
+	}()
+	return nil
+}
+func Unsafe() {
+	mu.Lock()
+	defer mu.Unlock()
+}
+`
+	prog, err := aster.LoadFile("../_out/defer_recover.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safe := prog.Lookup(aster.Fun, 0, "Safe")[0]
+	unsafe := prog.Lookup(aster.Fun, 0, "Unsafe")[0]
+	if !safe.HasRecover() || unsafe.HasRecover() {
+		t.Fatal("HasRecover: want true only for Safe")
+	}
+	if got := strings.Join(safe.DeferredCalls(), ","); got != "mu.Unlock,(func() literal)" {
+		t.Fatalf("DeferredCalls(Safe): got %s", got)
+	}
+	if got := strings.Join(unsafe.DeferredCalls(), ","); got != "mu.Unlock" {
+		t.Fatalf("DeferredCalls(Unsafe): got %s", got)
+	}
+}
+
+func TestNeverReturns(t *testing.T) {
+	var src = `package test
+import (
+	"log"
+	"os"
+)
+func Fail(msg string) {
+	if msg == "" {
+		os.Exit(1)
+	} else {
+		log.Fatal(msg)
+	}
+}
+func Loop() {
+	for {
+		println()
+	}
+}
+func Check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+func Guard(ok bool) {
+	if ok {
+		return
+	}
+	panic("not ok")
+}
+`
+	prog, err := aster.LoadFile("../_out/never_returns.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"Fail": true, "Loop": true, "Check": false, "Guard": false} {
+		if got := prog.Lookup(aster.Fun, 0, name)[0].NeverReturns(); got != want {
+			t.Fatalf("NeverReturns(%s): want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestResultType(t *testing.T) {
+	var src = `package test
+func Parse(s string) (n, base int, err error) { return 0, 10, nil }
+type Parser interface {
+	Parse(s string) (int, error)
+}
+`
+	prog, err := aster.LoadFile("../_out/result_type.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = map[aster.Facade]string{
+		prog.Lookup(aster.Fun, aster.Signature, "Parse")[0]: "int,int,error",
+	}
+	parser := prog.Lookup(aster.Typ, aster.Interface, "Parser")[0]
+	cases[parser.IfaceExplicitMethod(0)] = "int,error"
+	for fa, want := range cases {
+		tuple := fa.ResultType()
+		if tuple.TypKind() != aster.Tuple || tuple.Len() != len(strings.Split(want, ",")) {
+			t.Fatalf("ResultType(%s): want %s, got %d results", fa.Id(), want, tuple.Len())
+		}
+		var got []string
+		for i := 0; i < tuple.Len(); i++ {
+			tn, ok := tuple.At(i)
+			if !ok || tn.Node == nil || types.ExprString(tn.Node) != tn.Type.String() {
+				t.Fatalf("ResultType(%s).At(%d): got %v", fa.Id(), i, tn)
+			}
+			got = append(got, tn.Type.String())
+		}
+		if strings.Join(got, ",") != want {
+			t.Fatalf("ResultType(%s): want %s, got %s", fa.Id(), want, strings.Join(got, ","))
+		}
+		if _, ok := tuple.At(tuple.Len()); ok {
+			t.Fatalf("ResultType(%s).At(Len()): want false", fa.Id())
+		}
+	}
+}
+
+func TestNamedResults(t *testing.T) {
+	var src = `package test
+import "io"
+func Copy(w io.Writer, r io.Reader) (n int64, err error) { return }
+func Split(s string) (head, tail []string) { return }
+func Len(s string) int { return len(s) }
+var Nop = 1
+`
+	prog, err := aster.LoadFile("../_out/named_results.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = map[string]string{
+		"Copy":  "n:int64:int64,err:error:error",
+		"Split": "head:[]string:[]string,tail:[]string:[]string",
+		"Len":   "",
+		"Nop":   "",
+	}
+	for name, want := range cases {
+		var got []string
+		for _, r := range prog.Lookup(0, 0, name)[0].NamedResults() {
+			got = append(got, r.Name+":"+types.ExprString(r.Type.Node)+":"+r.Type.Type.String())
+		}
+		if strings.Join(got, ",") != want {
+			t.Fatalf("NamedResults(%s): want %s, got %s", name, want, strings.Join(got, ","))
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	var src = `package test
+import "strconv"
+func Atoi(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f := func() error { return nil }
+	_ = f
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+func Noop() {}
+`
+	prog, err := aster.LoadFile("../_out/return_statements.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atoi := prog.Lookup(aster.Fun, 0, "Atoi")[0]
+	list := atoi.ReturnStatements()
+	if len(list) != 3 {
+		t.Fatalf("ReturnStatements: want 3 statements, got %d", len(list))
+	}
+	idx := atoi.ErrorResultIndex()
+	if idx != 1 {
+		t.Fatalf("ErrorResultIndex: want 1, got %d", idx)
+	}
+	var errs []string
+	for _, ret := range list {
+		errs = append(errs, types.ExprString(ret.Results[idx]))
+	}
+	if got := strings.Join(errs, ","); got != "nil,err,nil" {
+		t.Fatalf("ReturnStatements: want the error results nil,err,nil, got %s", got)
+	}
+	noop := prog.Lookup(aster.Fun, 0, "Noop")[0]
+	if len(noop.ReturnStatements()) != 0 || noop.ErrorResultIndex() != -1 {
+		t.Fatal("Noop: want no return statements and no error result")
+	}
+}
+
+func TestMissingContext(t *testing.T) {
+	var src = `package test
+import (
+	"context"
+	"net/http"
+	"os"
+)
+func Fetch(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+func Get(url string) (*http.Response, error) { return Fetch(context.Background(), url) }
+func Remove(name string) error { return os.Remove(name) }
+func Add(a, b int) int { return a + b }
+func remove(name string) error { return os.Remove(name) }
+`
+	prog, err := aster.LoadFile("../_out/missing_context.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prog.Lookup(aster.Fun, 0, "Fetch")[0].HasContextFirst() || prog.Lookup(aster.Fun, 0, "Get")[0].HasContextFirst() {
+		t.Fatal("HasContextFirst: want true only for Fetch")
+	}
+	var names []string
+	for _, fa := range prog.MissingContext() {
+		names = append(names, fa.Name())
+	}
+	if got := strings.Join(names, ","); got != "Get,Remove" {
+		t.Fatalf("MissingContext: want Get,Remove, got %s", got)
+	}
+}
+
+func TestRenameParam(t *testing.T) {
+	var src = `package test
+import "strings"
+type T struct{ s string }
+func Join(s []string, x string) (n int) {
+	t := T{s: x}
+	for _, e := range s {
+		if x != "" {
+			n += len(e)
+		}
+	}
+	return n + len(strings.Join(s, x)) + len(t.s)
+}
+`
+	prog, err := aster.LoadFile("../_out/rename_param.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := prog.Lookup(aster.Fun, 0, "Join")[0]
+	for _, newName := range []string{"e", "n", "t", "strings", "len", "1s"} {
+		if err = join.RenameParam("x", newName); err == nil {
+			t.Fatalf("RenameParam(x, %s): want error", newName)
+		}
+	}
+	if err = join.RenameParam("y", "sep"); err == nil {
+		t.Fatal("RenameParam(y): want error")
+	}
+	if err = join.RenameParam("x", "sep"); err != nil {
+		t.Fatal(err)
+	}
+	if err = join.RenameParam("s", "elems"); err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	for _, want := range []string{
+		"func Join(elems []string, sep string) (n int) {",
+		"t := T{s: sep}",
+		"for _, e := range elems {",
+		`if sep != "" {`,
+		"return n + len(strings.Join(elems, sep)) + len(t.s)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("RenameParam: want %q in code", want)
+		}
+	}
+}
+
+func TestUncheckedErrors(t *testing.T) {
+	var src = `package test
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+func open(name string) (*os.File, error) { return os.Open(name) }
+func run() error {
+	f, err := open("a")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	_ = f.Sync()
+	n, _ := f.Write(nil)
+	_, _ = n, os.Remove("b")
+	var b strings.Builder
+	b.WriteString("x")
+	fmt.Println(n)
+	defer f.Close()
+	return f.Chmod(0644)
+}
+`
+	prog, err := aster.LoadFile("../_out/unchecked_errors.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pos := range prog.UncheckedErrors() {
+		got = append(got, fmt.Sprintf("%d:%d", pos.Line, pos.Column))
+	}
+	if want := "13:2,14:6,15:10,16:12"; strings.Join(got, ",") != want {
+		t.Fatalf("UncheckedErrors: want %s, got %s", want, strings.Join(got, ","))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortKey describes what facades are sorted by.
+type SortKey uint8
+
+// The list of possible sort keys.
+const (
+	SortByName     SortKey = iota // name
+	SortByPosition                // declaration position
+	SortByKind                    // ObjKind, then TypKind
+	SortByPackage                 // package path
+)
+
+// GroupKey describes what facades are grouped by.
+type GroupKey uint8
+
+// The list of possible group keys.
+const (
+	GroupByName     GroupKey = iota // name
+	GroupByPosition                 // declaration file name
+	GroupByKind                     // ObjKind
+	GroupByPackage                  // package path
+)
+
+// SortFacades sorts the list stably by the keys in priority order.
+// Sort by name if no key is specified.
+func SortFacades(list []Facade, by ...SortKey) {
+	if len(by) == 0 {
+		by = []SortKey{SortByName}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].(*facade), list[j].(*facade)
+		for _, key := range by {
+			if c := compareFacades(a, b, key); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// GroupFacades groups the list by the key, keeping the list order within each group.
+func GroupFacades(list []Facade, by GroupKey) map[string][]Facade {
+	groups := make(map[string][]Facade)
+	for _, fa := range list {
+		var key string
+		switch by {
+		case GroupByName:
+			key = fa.Name()
+		case GroupByPosition:
+			key = fa.(*facade).filename()
+		case GroupByKind:
+			key = fa.ObjKind().String()
+		case GroupByPackage:
+			key = fa.(*facade).pkgPath()
+		}
+		groups[key] = append(groups[key], fa)
+	}
+	return groups
+}
+
+// FacadesByFile groups the facades of the package by the file name of their declarations.
+func (p *PackageInfo) FacadesByFile() map[string][]Facade {
+	return GroupFacades(p.Lookup(0, 0, ""), GroupByPosition)
+}
+
+func compareFacades(a, b *facade, by SortKey) int {
+	switch by {
+	case SortByName:
+		return strings.Compare(a.Name(), b.Name())
+	case SortByPosition:
+		return int(a.ident.Pos()) - int(b.ident.Pos())
+	case SortByKind:
+		if a.ObjKind() != b.ObjKind() {
+			return int(a.ObjKind()) - int(b.ObjKind())
+		}
+		return int(a.TypKind()) - int(b.TypKind())
+	case SortByPackage:
+		return strings.Compare(a.pkgPath(), b.pkgPath())
+	}
+	return 0
+}
+
+func (fa *facade) filename() string {
+	return fa.pkg.prog.fset.File(fa.ident.Pos()).Name()
+}
+
+func (fa *facade) pkgPath() string {
+	return fa.pkg.Pkg.Path()
+}
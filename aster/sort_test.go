@@ -0,0 +1,111 @@
+// Copyright 2018 henrylee2cn. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aster_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/aster/aster"
+)
+
+var sortSrc = `package test
+type C int
+const B C = 1
+var A = 2
+func D(){}
+`
+
+func TestSortFacades(t *testing.T) {
+	prog, err := aster.LoadFile("../_out/sort.go", sortSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := prog.Lookup(0, 0, "")
+	aster.SortFacades(list, aster.SortByName)
+	var names []string
+	for _, fa := range list {
+		names = append(names, fa.Name())
+	}
+	var want = []string{"A", "B", "C", "D"}
+	if len(names) != len(want) {
+		t.Fatalf("SortFacades: want %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("SortFacades: want %v, got %v", want, names)
+		}
+	}
+	aster.SortFacades(list, aster.SortByPosition)
+	if list[0].Name() != "C" || list[3].Name() != "D" {
+		t.Fatalf("SortFacades by position: got %v", list)
+	}
+}
+
+func TestGroupFacades(t *testing.T) {
+	prog, err := aster.LoadFile("../_out/group.go", sortSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := aster.GroupFacades(prog.Lookup(0, 0, ""), aster.GroupByKind)
+	for kind, name := range map[aster.ObjKind]string{
+		aster.Typ: "C",
+		aster.Con: "B",
+		aster.Var: "A",
+		aster.Fun: "D",
+	} {
+		list := groups[kind.String()]
+		if len(list) != 1 || list[0].Name() != name {
+			t.Fatalf("GroupFacades[%s]: want [%s], got %v", kind, name, list)
+		}
+	}
+}
+
+func TestFacadesByFile(t *testing.T) {
+	var files = map[string]string{
+		"a.go": "package fbf\ntype A int\nfunc NewA() A { return 0 }\n",
+		"b.go": "package fbf\nvar B A\n",
+	}
+	dir := "../_out/facades_by_file"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog, err := aster.NewProgram().Import(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := prog.InitialPackages()[0].FacadesByFile()
+	if len(groups) != 2 {
+		t.Fatalf("FacadesByFile: want 2 files, got %d", len(groups))
+	}
+	var want = map[string]string{"a.go": "A,NewA", "b.go": "B"}
+	for filename, list := range groups {
+		aster.SortFacades(list)
+		var names []string
+		for _, fa := range list {
+			names = append(names, fa.Name())
+		}
+		if got := strings.Join(names, ","); got != want[filepath.Base(filename)] {
+			t.Fatalf("FacadesByFile[%s]: want %s, got %s", filename, want[filepath.Base(filename)], got)
+		}
+	}
+}
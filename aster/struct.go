@@ -15,10 +15,14 @@
 package aster
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/henrylee2cn/structtag"
@@ -34,7 +38,7 @@ func (fa *facade) structure() *types.Struct {
 		panic(fmt.Sprintf("aster: structure of non-Struct TypKind: %T", typ))
 	}
 	// initiate
-	if fa.structFields == nil {
+	if fa.structNode == nil {
 		numFields := t.NumFields()
 		fa.structFields = make([]*StructField, numFields)
 		for expr, tv := range fa.pkg.info.Types {
@@ -43,9 +47,23 @@ func (fa *facade) structure() *types.Struct {
 				if !ok {
 					n = expr.(*ast.CompositeLit).Type.(*ast.StructType)
 				}
-				expandFields(n.Fields)
-				for i := 0; i < numFields; i++ {
-					fa.structFields[i] = fa.pkg.newStructField(n.Fields.List[i], t.Field(i))
+				fa.structNode = n
+				i := 0
+				for _, field := range n.Fields.List {
+					if len(field.Names) == 0 {
+						fa.structFields[i] = fa.newStructField(field, nil, t.Field(i))
+						i++
+						continue
+					}
+					for _, name := range field.Names {
+						fa.structFields[i] = fa.newStructField(field, name, t.Field(i))
+						i++
+					}
+				}
+				if !fa.pkg.prog.keepFieldGroups {
+					for _, sf := range fa.structFields {
+						sf.split()
+					}
 				}
 				break
 			}
@@ -57,7 +75,8 @@ func (fa *facade) structure() *types.Struct {
 // NumFields returns the number of fields in the struct (including blank and embedded fields).
 // NOTE: Panic, if TypKind != Struct
 func (fa *facade) NumFields() int {
-	return fa.structure().NumFields()
+	fa.structure() // make sure initiated
+	return len(fa.structFields)
 }
 
 // Field returns the i'th field for 0 <= i < NumFields().
@@ -85,27 +104,710 @@ func (fa *facade) FieldByName(name string) (field *StructField, found bool) {
 	return nil, false
 }
 
+// FieldByPath returns the field by the dotted path of the field names, such as "Server.TLS.CertFile",
+// walking through the nested struct fields and the pointers to them, and the type of the field.
+// NOTE:
+//  Panic, if TypKind != Struct;
+//  Return false, if a field is not found, or an intermediate field is not a struct
+//  of a defined type of the program or of an anonymous struct type.
+func (fa *facade) FieldByPath(path string) (*StructField, TypeNode, bool) {
+	names := strings.Split(path, ".")
+	owner := fa
+	for i, name := range names {
+		sf, found := owner.FieldByName(name)
+		if !found {
+			break
+		}
+		if i == len(names)-1 {
+			return sf, TypeNode{Node: sf.node.Type, Type: sf.Type()}, true
+		}
+		next, ok := sf.structFacade()
+		if !ok {
+			break
+		}
+		owner = next
+	}
+	return nil, TypeNode{}, false
+}
+
+// structFacade returns the facade of the field's struct type, or of the pointee struct type.
+func (sf *StructField) structFacade() (*facade, bool) {
+	typ := types.Unalias(sf.obj.Type())
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = types.Unalias(ptr.Elem())
+	}
+	switch t := typ.(type) {
+	case *types.Named:
+		if _, ok := t.Underlying().(*types.Struct); ok {
+			return sf.pkg.prog.facadeOf(t.Origin().Obj())
+		}
+	case *types.Struct:
+		if _, ok := sf.obj.Type().(*types.Struct); ok && sf.name != nil {
+			return &facade{obj: sf.obj, pkg: sf.pkg, ident: sf.name}, true
+		}
+	}
+	return nil, false
+}
+
+// SortFields sorts the fields of the struct by less,
+// keeping each field's doc and line comments with it.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) SortFields(less func(a, b *StructField) bool) {
+	fa.structure() // make sure initiated
+	for _, sf := range fa.structFields {
+		sf.split()
+	}
+	sorted := make([]*StructField, len(fa.structFields))
+	copy(sorted, fa.structFields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	nodes := make([]*ast.Field, len(sorted))
+	for i, sf := range sorted {
+		nodes[i] = sf.node
+	}
+	fa.pkg.reorderFields(fa.structNode.Fields, nodes)
+	fa.structFields = sorted
+}
+
+// RemoveField removes the field by name, with its doc and line comments,
+// and reports whether the field was found.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) RemoveField(name string) bool {
+	fa.structure() // make sure initiated
+	for i, sf := range fa.structFields {
+		if sf.Name() != name {
+			continue
+		}
+		sf.split()
+		list := fa.structNode.Fields.List
+		for j, node := range list {
+			if node == sf.node {
+				fa.structNode.Fields.List = append(list[:j:j], list[j+1:]...)
+				break
+			}
+		}
+		fa.pkg.removeComments(sf.node.Doc, sf.node.Comment)
+		fa.structFields = append(fa.structFields[:i:i], fa.structFields[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Regroup merges the adjacent fields of the same type and tag into one field,
+// such as `A int` and `B int` into `A, B int`, which is the reverse of the
+// splitting done by editing a field of a group, or by expanding the groups on read.
+// The fields with a doc or line comment between them are not merged.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) Regroup() {
+	fa.structure() // make sure initiated
+	list := fa.structNode.Fields.List
+	if len(list) < 2 {
+		return
+	}
+	tokFile := fa.pkg.prog.fset.File(fa.structNode.Pos())
+	merged := list[:1]
+	for _, field := range list[1:] {
+		prev := merged[len(merged)-1]
+		if !fa.pkg.canMergeFields(tokFile, prev, field) {
+			merged = append(merged, field)
+			continue
+		}
+		if tokFile.Line(field.Pos()) > tokFile.Line(prev.End()) {
+			// print the names on the line of the group, and continue the group
+			// from the type of the field, without moving the lines of the file
+			for _, name := range field.Names {
+				name.NamePos = token.NoPos
+			}
+			prev.Type, prev.Tag = field.Type, field.Tag
+		}
+		prev.Names = append(prev.Names, field.Names...)
+		prev.Comment = field.Comment
+		for _, sf := range fa.structFields {
+			if sf.node == field {
+				sf.node, sf.tags.field = prev, prev
+			}
+		}
+	}
+	fa.structNode.Fields.List = merged
+}
+
+// canMergeFields reports whether field can be merged into prev,
+// the field on the same line (split from a group) or the line below the type of prev.
+func (p *PackageInfo) canMergeFields(tokFile *token.File, prev, field *ast.Field) bool {
+	if len(prev.Names) == 0 || len(field.Names) == 0 || prev.Comment != nil || field.Doc != nil {
+		return false
+	}
+	if tokFile == nil || !prev.Type.Pos().IsValid() || !field.Pos().IsValid() || !field.End().IsValid() {
+		return false
+	}
+	line := tokFile.Line(prev.End())
+	if l := tokFile.Line(field.Pos()); tokFile.Line(prev.Type.Pos()) != line ||
+		(l != line && l != line+1) || tokFile.Line(field.End()) != l {
+		return false
+	}
+	if (prev.Tag == nil) != (field.Tag == nil) || (prev.Tag != nil && prev.Tag.Value != field.Tag.Value) {
+		return false
+	}
+	a, err := p.FormatNode(prev.Type)
+	if err != nil {
+		return false
+	}
+	b, err := p.FormatNode(field.Type)
+	return err == nil && a == b
+}
+
+// FieldDiffKind describes how a field differs between two structs.
+type FieldDiffKind uint8
+
+// The list of possible field diff kinds.
+const (
+	FieldAdded    FieldDiffKind = iota // the field is only in the other struct
+	FieldRemoved                       // the field is only in this struct
+	FieldRetyped                       // the field type is changed
+	FieldRetagged                      // the field tag is changed
+)
+
+// FieldDiff is a difference of a field between two structs.
+type FieldDiff struct {
+	Name     string
+	Kind     FieldDiffKind
+	Old, New *StructField // Old is nil for FieldAdded, New is nil for FieldRemoved
+}
+
+// Diff compares the fields of the struct with the other struct by name,
+// and returns the removed, retyped and retagged fields in the order of this struct,
+// followed by the added fields in the order of the other struct.
+// The types are compared by their expressions relative to their own packages.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) Diff(other Facade) []FieldDiff {
+	fa.structure() // make sure initiated
+	o := other.(*facade)
+	o.structure() // make sure initiated
+	var diffs []FieldDiff
+	for _, sf := range fa.structFields {
+		nf, found := o.FieldByName(sf.Name())
+		if !found {
+			diffs = append(diffs, FieldDiff{Name: sf.Name(), Kind: FieldRemoved, Old: sf})
+			continue
+		}
+		if fa.typeString(sf.obj.Type()) != o.typeString(nf.obj.Type()) {
+			diffs = append(diffs, FieldDiff{Name: sf.Name(), Kind: FieldRetyped, Old: sf, New: nf})
+		}
+		if sf.Tags().String() != nf.Tags().String() {
+			diffs = append(diffs, FieldDiff{Name: sf.Name(), Kind: FieldRetagged, Old: sf, New: nf})
+		}
+	}
+	for _, nf := range o.structFields {
+		if _, found := fa.FieldByName(nf.Name()); !found {
+			diffs = append(diffs, FieldDiff{Name: nf.Name(), Kind: FieldAdded, New: nf})
+		}
+	}
+	return diffs
+}
+
+// MergeWith returns the code of a struct type which contains the fields of the struct
+// followed by the other fields of the other struct, with their tags, docs and line comments,
+// such as `struct {...}`.
+// The fields of the same name are resolved by onConflict, which returns one of them,
+// or nil to drop the field; the conflict is an error if onConflict is nil.
+// NOTE:
+//  Panic, if TypKind != Struct;
+//  The code may require importing the packages of the other struct's field types.
+func (fa *facade) MergeWith(other Facade, onConflict func(a, b *StructField) *StructField) (string, error) {
+	fa.structure() // make sure initiated
+	o := other.(*facade)
+	o.structure() // make sure initiated
+	var fields []*StructField
+	for _, sf := range fa.structFields {
+		if of, found := o.FieldByName(sf.Name()); found {
+			if onConflict == nil {
+				return "", fmt.Errorf("aster: field %s conflicts in %s and %s", sf.Name(), fa.Name(), o.Name())
+			}
+			sf = onConflict(sf, of)
+		}
+		if sf != nil {
+			fields = append(fields, sf)
+		}
+	}
+	for _, of := range o.structFields {
+		if _, found := fa.FieldByName(of.Name()); !found {
+			fields = append(fields, of)
+		}
+	}
+	qf := func(pkg *types.Package) string {
+		if pkg == fa.pkg.Pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+	var buf bytes.Buffer
+	buf.WriteString("package p\n\ntype _ struct {\n")
+	for _, sf := range fields {
+		writeComment(&buf, sf.Doc())
+		if !sf.Embedded() {
+			buf.WriteString(sf.Name() + " ")
+		}
+		buf.WriteString(types.TypeString(sf.obj.Type(), qf))
+		if tag := sf.Tags().String(); tag != "" {
+			buf.WriteString(" `" + tag + "`")
+		}
+		if c := strings.TrimSpace(sf.Comment()); c != "" {
+			buf.WriteString(" // " + strings.Replace(c, "\n", " ", -1))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	code, err := formatCode(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(code[strings.Index(code, "struct {"):]), nil
+}
+
+func writeComment(buf *bytes.Buffer, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		buf.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+	}
+}
+
+// UnmarshalableFields returns the unexported fields which are silently skipped by encoding/json,
+// excluding the blank fields, the fields tagged `json:"-"` and the embedded structs,
+// whose exported fields are promoted.
+// It returns nil if the type implements json.Marshaler or encoding.TextMarshaler.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) UnmarshalableFields() []*StructField {
+	fa.structure() // make sure initiated
+	if hasCustomMarshaler(fa.obj.Type()) {
+		return nil
+	}
+	var list []*StructField
+	for _, sf := range fa.structFields {
+		if sf.Exported() || sf.Name() == "_" || sf.WireName("json") == "" {
+			continue
+		}
+		if sf.Embedded() {
+			typ := sf.obj.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			if _, ok := typ.Underlying().(*types.Struct); ok {
+				continue
+			}
+		}
+		list = append(list, sf)
+	}
+	return list
+}
+
+// DuplicateTagNames returns the exported fields grouped by the wire names for the tag key,
+// which are shared by two or more fields, such as two fields tagged `json:"id"`.
+// The fields skipped by `key:"-"` are excluded. An empty result means no duplicates.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) DuplicateTagNames(tagKey string) map[string][]*StructField {
+	fa.structure() // make sure initiated
+	groups := make(map[string][]*StructField)
+	for _, sf := range fa.structFields {
+		if !sf.Exported() {
+			continue
+		}
+		if name := sf.WireName(tagKey); name != "" {
+			groups[name] = append(groups[name], sf)
+		}
+	}
+	for name, list := range groups {
+		if len(list) < 2 {
+			delete(groups, name)
+		}
+	}
+	return groups
+}
+
+// ContainsNoCopy reports whether the struct must not be copied by value,
+// as it contains a field, directly or in the nested struct and array fields,
+// of a type with the Lock and Unlock methods, such as sync.Mutex,
+// or of a known no-copy type, such as sync.WaitGroup.
+// NOTE: Panic, if TypKind != Struct
+func (fa *facade) ContainsNoCopy() bool {
+	return containsNoCopy(fa.structure(), make(map[types.Type]bool))
+}
+
+// noCopyTypes are the known types which must not be copied, keyed by package path and name.
+var noCopyTypes = map[string]bool{
+	"sync.Cond":      true,
+	"sync.Map":       true,
+	"sync.Mutex":     true,
+	"sync.Once":      true,
+	"sync.Pool":      true,
+	"sync.RWMutex":   true,
+	"sync.WaitGroup": true,
+}
+
+func containsNoCopy(typ types.Type, seen map[types.Type]bool) bool {
+	typ = types.Unalias(typ)
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+	if named, ok := typ.(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && noCopyTypes[obj.Pkg().Path()+"."+obj.Name()] {
+			return true
+		}
+		if hasLockUnlock(named) {
+			return true
+		}
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if containsNoCopy(t.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return containsNoCopy(t.Elem(), seen)
+	}
+	return false
+}
+
+// hasLockUnlock reports whether *typ has the methods Lock() and Unlock(), as the copylocks check of go vet.
+func hasLockUnlock(typ types.Type) bool {
+	if types.IsInterface(typ) {
+		return false
+	}
+	mset := types.NewMethodSet(types.NewPointer(typ))
+	for _, name := range []string{"Lock", "Unlock"} {
+		sel := mset.Lookup(nil, name)
+		if sel == nil {
+			return false
+		}
+		if sig := sel.Type().(*types.Signature); sig.Params().Len() != 0 || sig.Results().Len() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DeadStructFields returns the fields of the struct type t, which are never written
+// in the initial packages, so that they are always zero, in the order of the fields.
+// A field is written by a composite literal, an assignment, an increment or decrement,
+// taking its address, or calling a pointer method of it, including by its nested fields and elements.
+// NOTE:
+//  Panic, if TypKind of t != Struct;
+//  It is conservative: all fields are written, if a value of the type, or a pointer to it,
+//  is passed to a function of the reflect or encoding/... packages, or to a Decode or Unmarshal method.
+func (prog *Program) DeadStructFields(t Facade) []*StructField {
+	fa := t.(*facade)
+	fa.structure() // make sure initiated
+	fields := make(map[types.Object]bool, len(fa.structFields))
+	for _, sf := range fa.structFields {
+		fields[sf.obj] = true
+	}
+	written := make(map[types.Object]bool)
+	for _, pkg := range prog.InitialPackages() {
+		if pkg.writeFields(fa.obj.Type(), fields, written) {
+			return nil
+		}
+	}
+	var list []*StructField
+	for _, sf := range fa.structFields {
+		if sf.Name() != "_" && !written[sf.obj] {
+			list = append(list, sf)
+		}
+	}
+	return list
+}
+
+// writeFields collects the fields written in the package into written,
+// and reports whether a value of type typ is decoded by reflection.
+func (p *PackageInfo) writeFields(typ types.Type, fields, written map[types.Object]bool) (decoded bool) {
+	// mark marks the fields selected by the chain of selectors, indexes and dereferences.
+	mark := func(expr ast.Expr) {
+		for expr != nil {
+			switch x := ast.Unparen(expr).(type) {
+			case *ast.SelectorExpr:
+				written[p.info.Uses[x.Sel]] = true
+				expr = x.X
+			case *ast.IndexExpr:
+				expr = x.X
+			case *ast.StarExpr:
+				expr = x.X
+			default:
+				expr = nil
+			}
+		}
+	}
+	for _, f := range p.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.CompositeLit:
+				t := p.info.TypeOf(x)
+				if t == nil {
+					break
+				}
+				st, ok := t.Underlying().(*types.Struct)
+				if !ok {
+					break
+				}
+				for i, elt := range x.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok {
+							written[p.info.Uses[key]] = true
+						}
+					} else if i < st.NumFields() {
+						written[st.Field(i)] = true
+					}
+				}
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					mark(lhs)
+				}
+			case *ast.RangeStmt:
+				if x.Tok == token.ASSIGN {
+					mark(x.Key)
+					mark(x.Value)
+				}
+			case *ast.IncDecStmt:
+				mark(x.X)
+			case *ast.UnaryExpr:
+				if x.Op == token.AND {
+					mark(x.X)
+				}
+			case *ast.SelectorExpr:
+				if sel, ok := p.info.Selections[x]; ok && sel.Kind() == types.MethodVal {
+					if _, ptrRecv := sel.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer); ptrRecv {
+						mark(x.X)
+					}
+				}
+			case *ast.CallExpr:
+				decoded = decoded || p.decodesType(x, typ)
+			}
+			return !decoded
+		})
+	}
+	return decoded
+}
+
+// decodesType reports whether the call passes a value of type typ, or a pointer to it,
+// to a function of the reflect or encoding/... packages, or to a Decode or Unmarshal method.
+func (p *PackageInfo) decodesType(call *ast.CallExpr, typ types.Type) bool {
+	var id *ast.Ident
+	switch fn := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fn
+	case *ast.SelectorExpr:
+		id = fn.Sel
+	}
+	fn, ok := p.info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	path := fn.Pkg().Path()
+	if path != "reflect" && !strings.HasPrefix(path, "encoding/") &&
+		!strings.HasPrefix(fn.Name(), "Decode") && !strings.HasPrefix(fn.Name(), "Unmarshal") {
+		return false
+	}
+	for _, arg := range call.Args {
+		t := p.info.TypeOf(arg)
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if t != nil && types.Identical(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformFields calls apply for the fields matched by match, of the struct types
+// and the variables of anonymous struct types in the initial packages,
+// in the order of package path and declaration position.
+// apply may edit the field, such as its tags.
+// It stops at the first error of apply, and returns the number of the fields applied before it.
+func (prog *Program) TransformFields(match func(*StructField) bool, apply func(*StructField) error) (int, error) {
+	list := prog.Lookup(Typ|Var, Struct, "")
+	SortFacades(list, SortByPackage, SortByPosition)
+	var count int
+	for _, fa := range list {
+		if fa.IsAlias() {
+			continue
+		}
+		if _, ok := types.Unalias(fa.Object().Type()).(*types.Named); ok && fa.ObjKind() == Var {
+			continue // the fields belong to the type declaration
+		}
+		for i := 0; i < fa.NumFields(); i++ {
+			sf := fa.Field(i)
+			if !match(sf) {
+				continue
+			}
+			if err := apply(sf); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Embedders returns the defined struct types in the initial packages, which embed typ or *typ directly,
+// in the order of package path and declaration position.
+func (prog *Program) Embedders(typ types.Type) []Facade {
+	list := prog.Lookup(Typ, Struct, "")
+	SortFacades(list, SortByPackage, SortByPosition)
+	var embedders []Facade
+	for _, fa := range list {
+		if fa.IsAlias() {
+			continue
+		}
+		s := fa.(*facade).structure()
+		for i := 0; i < s.NumFields(); i++ {
+			v := s.Field(i)
+			if !v.Embedded() {
+				continue
+			}
+			t := v.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			if types.Identical(t, typ) {
+				embedders = append(embedders, fa)
+				break
+			}
+		}
+	}
+	return embedders
+}
+
+// hasCustomMarshaler reports whether typ or *typ has the method
+// MarshalJSON() ([]byte, error) or MarshalText() ([]byte, error).
+func hasCustomMarshaler(typ types.Type) bool {
+	if _, ok := typ.(*types.Pointer); !ok {
+		typ = types.NewPointer(typ)
+	}
+	mset := types.NewMethodSet(typ)
+	for _, name := range []string{"MarshalJSON", "MarshalText"} {
+		sel := mset.Lookup(nil, name)
+		if sel == nil {
+			continue
+		}
+		sig := sel.Type().(*types.Signature)
+		if sig.Params().Len() != 0 || sig.Results().Len() != 2 {
+			continue
+		}
+		if types.TypeString(sig.Results().At(0).Type(), nil) == "[]byte" &&
+			types.TypeString(sig.Results().At(1).Type(), nil) == "error" {
+			return true
+		}
+	}
+	return false
+}
+
 // StructField struct field object.
 type StructField struct {
-	node *ast.Field
-	obj  *types.Var
-	tags *Tags
+	pkg   *PackageInfo
+	owner *facade
+	node  *ast.Field
+	name  *ast.Ident // nil for embedded field
+	obj   *types.Var
+	tags  *Tags
 }
 
-func (p *PackageInfo) newStructField(node *ast.Field, obj *types.Var) *StructField {
+func (fa *facade) newStructField(node *ast.Field, name *ast.Ident, obj *types.Var) *StructField {
 	sf := &StructField{
-		node: node,
-		obj:  obj,
-		tags: newTags(node),
+		pkg:   fa.pkg,
+		owner: fa,
+		node:  node,
+		name:  name,
+		obj:   obj,
+		tags:  newTags(node),
 	}
+	sf.tags.owner = sf
 	return sf
 }
 
+// split splits the field from its group, such as `A, B int`,
+// before the field is edited.
+func (sf *StructField) split() {
+	group := sf.node
+	if len(group.Names) < 2 {
+		return
+	}
+	// keep the names with their positions, unlike ExpandFields
+	expanded := []*ast.Field{group}
+	for _, name := range group.Names[1:] {
+		field := &ast.Field{Names: []*ast.Ident{name}, Type: group.Type}
+		if group.Tag != nil {
+			field.Tag = &ast.BasicLit{ValuePos: group.Tag.ValuePos, Kind: group.Tag.Kind, Value: group.Tag.Value}
+		}
+		expanded = append(expanded, field)
+	}
+	group.Names = group.Names[:1]
+	fieldList := sf.owner.structNode.Fields
+	for i, field := range fieldList.List {
+		if field == group {
+			list := append(fieldList.List[:i:i], expanded...)
+			fieldList.List = append(list, fieldList.List[i+1:]...)
+			break
+		}
+	}
+	for _, other := range sf.owner.structFields {
+		if other.node != group {
+			continue
+		}
+		for _, field := range expanded {
+			if field.Names[0].Name == other.Name() {
+				other.node, other.tags.field = field, field
+				break
+			}
+		}
+	}
+}
+
 // Name returns the field's name.
 func (sf *StructField) Name() string {
 	return sf.obj.Name()
 }
 
+// Type returns the field's type.
+func (sf *StructField) Type() types.Type {
+	return sf.obj.Type()
+}
+
+// IsInterface reports whether the field's type is an interface type, such as io.Reader or error,
+// whose typed nil pointers are non-nil values.
+func (sf *StructField) IsInterface() bool {
+	return types.IsInterface(sf.obj.Type())
+}
+
+// ResolveType returns the facade of the field's defined type, such as io.Reader, following the aliases.
+// NOTE: Return false, if it is not a defined type declared in the program, such as int, error or []T.
+func (sf *StructField) ResolveType() (Facade, bool) {
+	named, ok := types.Unalias(sf.obj.Type()).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	fa, ok := sf.pkg.prog.facadeOf(named.Origin().Obj())
+	if !ok {
+		return nil, false
+	}
+	return fa, true
+}
+
+// Position returns the source position of the field's name,
+// or of the field's type for an embedded field.
+func (sf *StructField) Position() token.Position {
+	pos := sf.obj.Pos()
+	if sf.Embedded() {
+		pos = sf.node.Type.Pos()
+	} else if sf.name != nil && sf.name.Pos().IsValid() {
+		pos = sf.name.Pos()
+	}
+	return sf.pkg.prog.fset.Position(pos)
+}
+
 // Exported reports whether the object is exported (starts with a capital letter).
 // It doesn't take into account whether the object is in a local (function) scope
 // or not.
@@ -113,6 +815,23 @@ func (sf *StructField) Exported() bool {
 	return sf.obj.Exported()
 }
 
+// WireName returns the field's name for the tag key, such as json, xml, etc..
+// It is the first token of the tag value, or the field name if the tag is absent
+// or has an empty name, or "" if the field is skipped by `key:"-"`.
+func (sf *StructField) WireName(tagKey string) string {
+	tag, err := sf.tags.Get(tagKey)
+	if err != nil {
+		return sf.Name()
+	}
+	if tag.Name == "-" && len(tag.Options) == 0 {
+		return ""
+	}
+	if tag.Name == "" {
+		return sf.Name()
+	}
+	return tag.Name
+}
+
 // Tags returns the field's tag object.
 func (sf *StructField) Tags() *Tags {
 	return sf.tags
@@ -145,6 +864,22 @@ func (sf *StructField) Comment() string {
 	return sf.node.Comment.Text()
 }
 
+// HasDirective reports whether the lead or line comment of the field has the directive line,
+// such as `//aster:nobuild` for the directive "aster:nobuild".
+func (sf *StructField) HasDirective(directive string) bool {
+	for _, g := range []*ast.CommentGroup{sf.node.Doc, sf.node.Comment} {
+		if g == nil {
+			continue
+		}
+		for _, c := range g.List {
+			if c.Text == "//"+directive || strings.HasPrefix(c.Text, "//"+directive+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // A Tags is the tag string in a struct field.
 //
 // By convention, tag strings are a concatenation of
@@ -154,6 +889,7 @@ func (sf *StructField) Comment() string {
 // and colon (U+003A ':').  Each value is quoted using U+0022 '"'
 // characters and Go string literal syntax.
 type Tags struct {
+	owner *StructField
 	field *ast.Field
 	tags  *structtag.Tags
 }
@@ -187,7 +923,11 @@ func newTags(field *ast.Field) *Tags {
 func (s *Tags) reparse() (err error) {
 	var value string
 	if s.field.Tag != nil {
-		value = strings.Trim(s.field.Tag.Value, "`")
+		// the tag may be a raw or an interpreted string literal
+		value, err = strconv.Unquote(s.field.Tag.Value)
+		if err != nil {
+			value = strings.Trim(s.field.Tag.Value, "`")
+		}
 	}
 	s.tags, err = structtag.Parse(value)
 	if err != nil {
@@ -196,16 +936,29 @@ func (s *Tags) reparse() (err error) {
 	return err
 }
 
+// PreserveTagOrder reports whether editing the tags keeps the keys in their original order,
+// with the new keys appended, or sorts the keys if false.
+var PreserveTagOrder = true
+
 func (s *Tags) resetValue() {
-	sort.Sort(s.tags)
-	value := s.tags.String()
+	if s.owner != nil {
+		s.owner.split() // the tag of a group is shared
+	}
+	if !PreserveTagOrder {
+		sort.Sort(s.tags)
+	}
+	value := s.String()
 	if value == "" {
 		s.field.Tag = nil
 	} else {
 		if s.field.Tag == nil {
-			s.field.Tag = &ast.BasicLit{}
+			s.field.Tag = &ast.BasicLit{Kind: token.STRING}
+		}
+		if strconv.CanBackquote(value) {
+			s.field.Tag.Value = "`" + value + "`"
+		} else {
+			s.field.Tag.Value = strconv.Quote(value)
 		}
-		s.field.Tag.Value = "`" + value + "`"
 	}
 }
 
@@ -257,12 +1010,26 @@ func (s *Tags) Set(tag *Tag) error {
 	return err
 }
 
-// String reassembles the tags into a valid literal tag field representation
+// String reassembles the tags into a valid literal tag field representation,
+// quoting the values with escapes, such as `desc:"a \"quoted\" value"`.
 func (s *Tags) String() string {
-	return s.tags.String()
+	var buf strings.Builder
+	for i, tag := range s.tags.Tags() {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(tag.Key)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Quote(tag.Value()))
+	}
+	return buf.String()
 }
 
-func expandFields(fieldList *ast.FieldList) {
+// ExpandFields splits the fields which declare several names, such as `A, B int`,
+// into one field per name, as the struct API does on the first access,
+// or only for the edited fields if the program keeps the field groups.
+// NOTE: It mutates fieldList in place.
+func ExpandFields(fieldList *ast.FieldList) {
 	if fieldList == nil {
 		return
 	}
@@ -282,3 +1049,143 @@ func expandFields(fieldList *ast.FieldList) {
 	}
 	fieldList.List = list
 }
+
+// reorderFields replaces the fields of the list with the same fields in the new order.
+//
+// The printer places comments by position, so each field is moved, by whole lines,
+// into the lines of its slot in the new order, together with the comments of the CommentMap
+// associated with it, such as its doc and line comments; the gaps between the slots stay in place.
+// The lines of the file are kept, so a column beyond the end of a target line is clamped to it.
+// NOTE: The fields are left in place, if two of them, with their comments, share a line.
+func (p *PackageInfo) reorderFields(fieldList *ast.FieldList, fields []*ast.Field) {
+	old := fieldList.List
+	f := p.fileOf(fieldList.Pos())
+	fset := p.prog.fset
+	tokFile := fset.File(fieldList.Pos())
+	if f == nil || tokFile == nil || len(old) < 2 {
+		fieldList.List = fields
+		return
+	}
+	// the comments are associated by the nodes in the source order
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+	fieldList.List = fields
+	groups := make(map[*ast.Field][]*ast.CommentGroup, len(old))
+	first, last := make([]int, len(old)), make([]int, len(old))
+	index := make(map[*ast.Field]int, len(old))
+	for i, field := range old {
+		index[field] = i
+		start, end := field.Pos(), field.End()
+		seen := make(map[*ast.CommentGroup]bool)
+		ast.Inspect(field, func(n ast.Node) bool {
+			if n == nil {
+				return false
+			}
+			for _, g := range cmap[n] {
+				if !seen[g] {
+					seen[g] = true
+					groups[field] = append(groups[field], g)
+					if g.Pos() < start {
+						start = g.Pos()
+					}
+					if g.End() > end {
+						end = g.End()
+					}
+				}
+			}
+			return true
+		})
+		if !start.IsValid() || !tokenFileContainsPos(tokFile, start) || !tokenFileContainsPos(tokFile, end-1) {
+			return // the positions are unreliable, such as for expanded fields
+		}
+		first[i], last[i] = tokFile.Line(start), tokFile.Line(end-1)
+		if i > 0 && first[i] <= last[i-1] {
+			return
+		}
+	}
+	lineEnd := func(line int) token.Pos {
+		if line < tokFile.LineCount() {
+			return tokFile.LineStart(line+1) - 1
+		}
+		return token.Pos(tokFile.Base() + tokFile.Size())
+	}
+	moved := make(map[interface{}]bool)
+	move := func(node ast.Node, lines int) {
+		walkPos(node, moved, func(pos token.Pos) token.Pos {
+			if lines == 0 || !tokenFileContainsPos(tokFile, pos) {
+				return pos
+			}
+			line := tokFile.Line(pos)
+			target := line + lines
+			if np := tokFile.LineStart(target) + (pos - tokFile.LineStart(line)); np < lineEnd(target) {
+				return np
+			}
+			return lineEnd(target)
+		})
+	}
+	line := first[0]
+	for k, field := range fields {
+		i := index[field]
+		move(field, line-first[i])
+		for _, g := range groups[field] {
+			move(g, line-first[i])
+		}
+		line += last[i] - first[i] + 1
+		if k+1 < len(old) {
+			line += first[k+1] - last[k] - 1 // keep the k'th gap between fields in place
+		}
+	}
+	f.Comments = cmap.Comments()
+}
+
+// shiftPos adds delta to all valid positions of the node and its descendants.
+// The moved set avoids moving a shared node twice.
+func shiftPos(node ast.Node, delta token.Pos, moved map[interface{}]bool) {
+	if delta == 0 {
+		return
+	}
+	walkPos(node, moved, func(pos token.Pos) token.Pos {
+		if pos.IsValid() {
+			return pos + delta
+		}
+		return pos
+	})
+}
+
+// resetPos clears the positions of the node and its descendants,
+// except that the braces of struct and interface types are set to pos,
+// so that the printer lays them out on a single line.
+func resetPos(node ast.Node, pos token.Pos) {
+	walkPos(node, make(map[interface{}]bool), func(token.Pos) token.Pos {
+		return token.NoPos
+	})
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.StructType:
+			x.Struct, x.Fields.Opening, x.Fields.Closing = pos, pos, pos
+		case *ast.InterfaceType:
+			x.Interface, x.Methods.Opening, x.Methods.Closing = pos, pos, pos
+		}
+		return true
+	})
+}
+
+func walkPos(node ast.Node, visited map[interface{}]bool, fn func(token.Pos) token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil || visited[n] {
+			return false
+		}
+		visited[n] = true
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if fv := v.Field(i); fv.Type() == posType && fv.CanSet() {
+				fv.SetInt(int64(fn(token.Pos(fv.Int()))))
+			}
+		}
+		return true
+	})
+}
@@ -15,6 +15,14 @@
 package aster_test
 
 import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/henrylee2cn/aster/aster"
@@ -103,3 +111,751 @@ var S2 = struct{
 		t.Fatal(err)
 	}
 }
+
+func TestWireName(t *testing.T) {
+	var src = `package test
+type S struct {
+	A int ` + "`json:\"a,omitempty\" xml:\"x_a\"`" + `
+	B int
+	C int ` + "`json:\"-\"`" + `
+	D int ` + "`json:\"-,\"`" + `
+	E int ` + "`json:\",string\"`" + `
+}
+`
+	prog, err := aster.LoadFile("../_out/wire_name.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "S")[0]
+	var want = map[string]string{
+		"A": "a",
+		"B": "B",
+		"C": "",
+		"D": "-",
+		"E": "E",
+	}
+	for name, wireName := range want {
+		field, _ := s.FieldByName(name)
+		if got := field.WireName("json"); got != wireName {
+			t.Fatalf("WireName(json) of %s: want %q, got %q", name, wireName, got)
+		}
+	}
+	a, _ := s.FieldByName("A")
+	if got := a.WireName("xml"); got != "x_a" {
+		t.Fatalf("WireName(xml) of A: want %q, got %q", "x_a", got)
+	}
+}
+
+func TestTagsEscapedQuotes(t *testing.T) {
+	var src = "package test\ntype S struct {\n" +
+		"\tA string `json:\"name\" desc:\"a \\\"quoted\\\" description\"`\n" +
+		"\tB int \"json:\\\"b\\\"\"\n" +
+		"}\n"
+	prog, err := aster.LoadFile("../_out/tags_escaped_quotes.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "S")[0]
+	a, _ := s.FieldByName("A")
+	desc, err := a.Tags().Get("desc")
+	if err != nil || desc.Name != `a "quoted" description` {
+		t.Fatalf("Get(desc): want %q, got %v, %v", `a "quoted" description`, desc, err)
+	}
+	if err = a.Tags().Set(&aster.Tag{Key: "json", Name: "name", Options: []string{"omitempty"}}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := s.FieldByName("B")
+	if got := b.WireName("json"); got != "b" {
+		t.Fatalf("WireName(json) of B: want b, got %q", got)
+	}
+	b.Tags().AddOptions("json", "string")
+	b.Tags().Set(&aster.Tag{Key: "note", Name: "`x`"})
+
+	pkg := prog.Package("test")
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	f, err := parser.ParseFile(token.NewFileSet(), "", code, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []reflect.StructTag
+	ast.Inspect(f, func(n ast.Node) bool {
+		if field, ok := n.(*ast.Field); ok && field.Tag != nil {
+			value, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tags = append(tags, reflect.StructTag(value))
+		}
+		return true
+	})
+	if len(tags) != 2 {
+		t.Fatalf("want 2 tags, got %d", len(tags))
+	}
+	if got := tags[0].Get("desc"); got != `a "quoted" description` {
+		t.Fatalf("desc: want %q, got %q", `a "quoted" description`, got)
+	}
+	if got := tags[0].Get("json"); got != "name,omitempty" {
+		t.Fatalf("json: want %q, got %q", "name,omitempty", got)
+	}
+	if got := tags[1].Get("json"); got != "b,string" {
+		t.Fatalf("json: want %q, got %q", "b,string", got)
+	}
+	if got := tags[1].Get("note"); got != "`x`" {
+		t.Fatalf("note: want %q, got %q", "`x`", got)
+	}
+}
+
+func TestSortFields(t *testing.T) {
+	var src = `package test
+type S struct {
+	// C doc
+	C string ` + "`json:\"c\"`" + ` // C comment
+
+	// a floating comment
+
+	// B doc
+	// is long
+	B struct {
+		X int // X comment
+	}
+	A int // A comment
+	// D doc
+	D int
+}
+`
+	prog, err := aster.LoadFile("../_out/sort_fields.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	s := pkg.Lookup(aster.Typ, aster.Struct, "S")[0]
+	s.SortFields(func(a, b *aster.StructField) bool {
+		return a.Name() < b.Name()
+	})
+	for i, name := range []string{"A", "B", "C", "D"} {
+		if got := s.Field(i).Name(); got != name {
+			t.Fatalf("SortFields: Field(%d) want %s, got %s", i, name, got)
+		}
+	}
+	code, err := pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(code)
+	var want = `package test
+
+type S struct {
+	A int // A comment
+
+	// a floating comment
+
+	// B doc
+	// is long
+	B struct {
+		X int // X comment
+	}
+	// C doc
+	C string ` + "`json:\"c\"`" + ` // C comment
+	// D doc
+	D int
+}
+`
+	if code != want {
+		t.Fatalf("SortFields: want:\n%s\ngot:\n%s", want, code)
+	}
+
+	if !s.RemoveField("C") || s.RemoveField("C") {
+		t.Fatal("RemoveField: want to remove C only once")
+	}
+	if s.NumFields() != 3 {
+		t.Fatalf("RemoveField: want 3 fields, got %d", s.NumFields())
+	}
+	code, err = pkg.FormatNode(pkg.Files()[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, "C doc") || strings.Contains(code, "C comment") {
+		t.Fatalf("RemoveField: the comments of C are left:\n%s", code)
+	}
+}
+
+func TestExpandFields(t *testing.T) {
+	fieldList := &ast.FieldList{List: []*ast.Field{
+		{
+			Names: []*ast.Ident{ast.NewIdent("A"), ast.NewIdent("B")},
+			Type:  ast.NewIdent("int"),
+			Tag:   &ast.BasicLit{Kind: token.STRING, Value: "`json:\"x\"`"},
+		},
+		{
+			Names: []*ast.Ident{ast.NewIdent("C")},
+			Type:  ast.NewIdent("string"),
+		},
+	}}
+	aster.ExpandFields(fieldList)
+	if len(fieldList.List) != 3 {
+		t.Fatalf("ExpandFields: want 3 fields, got %d", len(fieldList.List))
+	}
+	for i, name := range []string{"A", "B", "C"} {
+		field := fieldList.List[i]
+		if len(field.Names) != 1 || field.Names[0].Name != name {
+			t.Fatalf("ExpandFields: field %d want %s, got %v", i, name, field.Names)
+		}
+	}
+	if b := fieldList.List[1]; b.Type.(*ast.Ident).Name != "int" || b.Tag.Value != "`json:\"x\"`" {
+		t.Fatalf("ExpandFields: field B want type and tag of the group, got %v %v", b.Type, b.Tag)
+	}
+}
+
+func TestFieldIsInterface(t *testing.T) {
+	var src = `package test
+import "io"
+type Conn struct {
+	R    io.Reader
+	Err  error
+	Buf  []byte
+	next *Conn
+}
+`
+	prog, err := aster.LoadFile("../_out/field_is_interface.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "Conn")[0]
+	var want = map[string]bool{"R": true, "Err": true, "Buf": false, "next": false}
+	for name, isIface := range want {
+		field, _ := s.FieldByName(name)
+		if field.IsInterface() != isIface {
+			t.Fatalf("IsInterface of %s: want %v", name, isIface)
+		}
+	}
+	r, _ := s.FieldByName("R")
+	reader, ok := r.ResolveType()
+	if !ok || reader.Name() != "Reader" || reader.TypKind() != aster.Interface || !reader.IsNamed("io", "Reader") {
+		t.Fatalf("ResolveType of R: want the io.Reader interface, got %v, %v", reader, ok)
+	}
+	for _, name := range []string{"Err", "Buf", "next"} {
+		field, _ := s.FieldByName(name)
+		if _, ok := field.ResolveType(); ok {
+			t.Fatalf("ResolveType of %s: want false", name)
+		}
+	}
+}
+
+func TestFieldPosition(t *testing.T) {
+	var src = `package test
+type M int
+type S struct {
+	A string
+	B, C int
+	*M
+}
+`
+	prog, err := aster.LoadFile("../_out/field_position.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "S")[0]
+	var want = map[string]string{
+		"A": "../_out/field_position.go:4:2",
+		"B": "../_out/field_position.go:5:2",
+		"C": "../_out/field_position.go:5:5",
+		"M": "../_out/field_position.go:6:2",
+	}
+	for name, pos := range want {
+		field, _ := s.FieldByName(name)
+		if got := field.Position().String(); got != pos {
+			t.Fatalf("Position of %s: want %s, got %s", name, pos, got)
+		}
+	}
+}
+
+func TestUnmarshalableFields(t *testing.T) {
+	var src = `package test
+type inner struct{ X int }
+type myInt int
+type A struct {
+	Name  string
+	age   int
+	_     int
+	token string ` + "`json:\"-\"`" + `
+	inner
+	myInt
+}
+type B struct {
+	secret string
+}
+func (b *B) MarshalJSON() ([]byte, error) { return []byte(b.secret), nil }
+`
+	prog, err := aster.LoadFile("../_out/unmarshalable_fields.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := prog.Lookup(aster.Typ, aster.Struct, "A")[0]
+	var names []string
+	for _, sf := range a.UnmarshalableFields() {
+		names = append(names, sf.Name())
+	}
+	if strings.Join(names, ",") != "age,myInt" {
+		t.Fatalf("UnmarshalableFields(A): want age,myInt, got %v", names)
+	}
+	b := prog.Lookup(aster.Typ, aster.Struct, "B")[0]
+	if list := b.UnmarshalableFields(); len(list) != 0 {
+		t.Fatalf("UnmarshalableFields(B): want none for a custom MarshalJSON, got %d", len(list))
+	}
+}
+
+func TestDuplicateTagNames(t *testing.T) {
+	var src = `package test
+type User struct {
+	ID     int    ` + "`json:\"id\"`" + `
+	UserID string ` + "`json:\"id\"`" + `
+	Name   string
+	Alias  string ` + "`json:\"Name\" xml:\"alias\"`" + `
+	A, B   int    ` + "`json:\"-\"`" + `
+	id     int    ` + "`json:\"ref\"`" + `
+	Ref    int    ` + "`json:\"ref\"`" + `
+}
+`
+	prog, err := aster.LoadFile("../_out/duplicate_tag_names.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := prog.Lookup(aster.Typ, aster.Struct, "User")[0]
+	dups := s.DuplicateTagNames("json")
+	if len(dups) != 2 {
+		t.Fatalf("DuplicateTagNames(json): want 2 names, got %v", dups)
+	}
+	for name, want := range map[string]string{"id": "ID,UserID", "Name": "Name,Alias"} {
+		var names []string
+		for _, sf := range dups[name] {
+			names = append(names, sf.Name())
+		}
+		if strings.Join(names, ",") != want {
+			t.Fatalf("DuplicateTagNames(json)[%s]: want %s, got %v", name, want, names)
+		}
+	}
+	if dups = s.DuplicateTagNames("xml"); len(dups) != 0 {
+		t.Fatalf("DuplicateTagNames(xml): want none, got %v", dups)
+	}
+}
+
+func TestRegroup(t *testing.T) {
+	var src = `package test
+type S struct {
+	A, B int ` + "`json:\"x\"`" + `
+	C    int
+	// D doc
+	D int
+	E string
+}
+`
+	prog, err := aster.NewProgram().SetKeepFieldGroups(true).AddFile("../_out/regroup.go", src).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("test")
+	s := pkg.Lookup(aster.Typ, aster.Struct, "S")[0]
+	format := func() string {
+		code, err := pkg.FormatNode(pkg.Files()[0].File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	if s.NumFields() != 5 {
+		t.Fatalf("NumFields: want 5, got %d", s.NumFields())
+	}
+	b, _ := s.FieldByName("B")
+	if tag, _ := b.Tags().Get("json"); tag == nil || tag.Name != "x" {
+		t.Fatalf("Tags of B: want the tag of the group, got %v", tag)
+	}
+	if code := format(); !strings.Contains(code, "\tA, B int `json:\"x\"`\n") {
+		t.Fatalf("reading fields: want the group kept:\n%s", code)
+	}
+
+	b.Tags().Delete("json")
+	var want = "\tA int `json:\"x\"`\n\tB int\n\tC int\n"
+	if code := format(); !strings.Contains(code, want) {
+		t.Fatalf("editing B: want the group split:\n%s", code)
+	}
+	a, _ := s.FieldByName("A")
+	a.Tags().Delete("json")
+	s.Regroup()
+	want = `type S struct {
+	A, B, C int
+	// D doc
+	D int
+	E string
+}`
+	if code := format(); !strings.Contains(code, want) {
+		t.Fatalf("Regroup: want:\n%s\ngot:\n%s", want, code)
+	}
+	for i, name := range []string{"A", "B", "C", "D", "E"} {
+		if got := s.Field(i).Name(); got != name {
+			t.Fatalf("Regroup: Field(%d) want %s, got %s", i, name, got)
+		}
+	}
+	if line := s.Field(4).Position().Line; line != 7 {
+		t.Fatalf("Regroup: want the line of E unchanged, got %d", line)
+	}
+
+	// the groups are expanded on read by default
+	prog, err = aster.LoadFile("../_out/regroup_default.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg = prog.Package("test")
+	s = pkg.Lookup(aster.Typ, aster.Struct, "S")[0]
+	if s.NumFields() != 5 {
+		t.Fatalf("NumFields: want 5, got %d", s.NumFields())
+	}
+	if code := format(); !strings.Contains(code, "\tA int `json:\"x\"`\n\tB int `json:\"x\"`\n\tC int\n") {
+		t.Fatalf("reading fields: want the group expanded:\n%s", code)
+	}
+	s.Regroup()
+	want = `type S struct {
+	A, B int ` + "`json:\"x\"`" + `
+	C    int
+	// D doc
+	D int
+	E string
+}`
+	if code := format(); !strings.Contains(code, want) {
+		t.Fatalf("Regroup: want:\n%s\ngot:\n%s", want, code)
+	}
+}
+
+func TestStructDiff(t *testing.T) {
+	var src = `package test
+type V1 struct {
+	ID   int
+	Name string ` + "`json:\"name\"`" + `
+	Age  int
+	Note string
+}
+type V2 struct {
+	ID    int64
+	Name  string ` + "`json:\"full_name\"`" + `
+	Age   int
+	Email string
+}
+`
+	prog, err := aster.LoadFile("../_out/struct_diff.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := prog.Lookup(aster.Typ, aster.Struct, "V1")[0]
+	v2 := prog.Lookup(aster.Typ, aster.Struct, "V2")[0]
+	diffs := v1.Diff(v2)
+	var want = []struct {
+		name string
+		kind aster.FieldDiffKind
+	}{
+		{"ID", aster.FieldRetyped},
+		{"Name", aster.FieldRetagged},
+		{"Note", aster.FieldRemoved},
+		{"Email", aster.FieldAdded},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("Diff: want %d diffs, got %d", len(want), len(diffs))
+	}
+	for i, w := range want {
+		if d := diffs[i]; d.Name != w.name || d.Kind != w.kind {
+			t.Fatalf("Diff[%d]: want %s %d, got %s %d", i, w.name, w.kind, d.Name, d.Kind)
+		}
+	}
+	if d := diffs[0]; d.Old.Name() != "ID" || d.New.Name() != "ID" {
+		t.Fatal("Diff[0]: want the old and new fields")
+	}
+	if d := diffs[3]; d.Old != nil || d.New.Name() != "Email" {
+		t.Fatal("Diff[3]: want only the new field")
+	}
+	if diffs := v1.Diff(v1); len(diffs) != 0 {
+		t.Fatalf("Diff with itself: want none, got %d", len(diffs))
+	}
+}
+
+func TestMergeWith(t *testing.T) {
+	var src = `package test
+type User struct {
+	// ID is the user id.
+	ID   int    ` + "`json:\"id\"`" + `
+	Name string // display name
+}
+type Profile struct {
+	ID    int64  ` + "`json:\"profile_id\"`" + `
+	Email string ` + "`json:\"email\"`" + `
+}
+`
+	prog, err := aster.LoadFile("../_out/merge_with.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := prog.Lookup(aster.Typ, aster.Struct, "User")[0]
+	profile := prog.Lookup(aster.Typ, aster.Struct, "Profile")[0]
+	if _, err = user.MergeWith(profile, nil); err == nil {
+		t.Fatal("MergeWith: want error for the conflict of ID")
+	}
+	code, err := user.MergeWith(profile, func(a, b *aster.StructField) *aster.StructField { return a })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = `struct {
+	// ID is the user id.
+	ID    int    ` + "`json:\"id\"`" + `
+	Name  string // display name
+	Email string ` + "`json:\"email\"`" + `
+}`
+	if code != want {
+		t.Fatalf("MergeWith: want:\n%s\ngot:\n%s", want, code)
+	}
+	mustCompile(t, src, "type Merged "+code)
+	code, err = user.MergeWith(profile, func(a, b *aster.StructField) *aster.StructField { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, "ID") {
+		t.Fatalf("MergeWith: want ID dropped:\n%s", code)
+	}
+}
+
+func TestTransformFields(t *testing.T) {
+	var src = `package test
+type User struct {
+	Name  string ` + "`json:\"name\"`" + `
+	Email string ` + "`json:\"email,omitempty\"`" + `
+	Age   int    ` + "`json:\"age\"`" + `
+	Note  string
+}
+var Config = struct {
+	Host string ` + "`json:\"host\"`" + `
+}{}
+var U User
+`
+	prog, err := aster.LoadFile("../_out/transform_fields.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isJSONString := func(sf *aster.StructField) bool {
+		_, err := sf.Tags().Get("json")
+		return err == nil && sf.Type().String() == "string"
+	}
+	n, err := prog.TransformFields(isJSONString, func(sf *aster.StructField) error {
+		sf.Tags().AddOptions("json", "omitempty")
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("TransformFields: want 3 fields, got %d, %v", n, err)
+	}
+	codes, err := prog.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := codes["../_out/transform_fields.go"]
+	for _, want := range []string{
+		"`json:\"name,omitempty\"`",
+		"`json:\"email,omitempty\"`",
+		"`json:\"age\"`",
+		"`json:\"host,omitempty\"`",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("TransformFields: want %s in code:\n%s", want, code)
+		}
+	}
+	stop := errors.New("stop")
+	n, err = prog.TransformFields(isJSONString, func(sf *aster.StructField) error {
+		if sf.Name() == "Email" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("TransformFields: want 1 field before the error, got %d, %v", n, err)
+	}
+}
+
+func TestContainsNoCopy(t *testing.T) {
+	var src = `package test
+import "sync"
+type Counter struct {
+	sync.Mutex
+	n int
+}
+type Group struct {
+	wg [2]sync.WaitGroup
+}
+type noCopy struct{}
+func (*noCopy) Lock()   {}
+func (*noCopy) Unlock() {}
+type Pool struct {
+	_    noCopy
+	next *Counter
+}
+type Wrapper struct{ c Counter }
+type Plain struct {
+	mu *sync.Mutex
+	l  sync.Locker
+}
+`
+	prog, err := aster.LoadFile("../_out/contains_no_copy.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"Counter": true,
+		"Group":   true,
+		"Pool":    true,
+		"Wrapper": true,
+		"Plain":   false,
+	} {
+		if got := prog.Lookup(aster.Typ, aster.Struct, name)[0].ContainsNoCopy(); got != want {
+			t.Fatalf("ContainsNoCopy(%s): want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestFieldByPath(t *testing.T) {
+	var src = `package test
+type TLS struct {
+	CertFile string
+}
+type Server struct {
+	Addr string
+	TLS  *TLS
+	Log  struct {
+		Level int
+	}
+}
+type Config struct {
+	Server Server
+	Name   string
+}
+`
+	prog, err := aster.LoadFile("../_out/field_by_path.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := prog.Lookup(aster.Typ, aster.Struct, "Config")[0]
+	for path, want := range map[string]string{
+		"Server.TLS.CertFile": "string",
+		"Server.Log.Level":    "int",
+		"Server.TLS":          "*TLS",
+		"Name":                "string",
+	} {
+		sf, tn, ok := cfg.FieldByPath(path)
+		if !ok {
+			t.Fatalf("FieldByPath(%s): not found", path)
+		}
+		if name := path[strings.LastIndex(path, ".")+1:]; sf.Name() != name || types.ExprString(tn.Node) != want {
+			t.Fatalf("FieldByPath(%s): want %s %s, got %s %s", path, name, want, sf.Name(), types.ExprString(tn.Node))
+		}
+	}
+	for _, path := range []string{"Name.Len", "Server.Port", "Server.Addr.X", ""} {
+		if _, _, ok := cfg.FieldByPath(path); ok {
+			t.Fatalf("FieldByPath(%s): want false", path)
+		}
+	}
+}
+
+func TestPreserveTagOrder(t *testing.T) {
+	var src = "package test\ntype S struct {\n" +
+		"\tA string `yaml:\"a\" json:\"a\"`\n" +
+		"}\n"
+	defer func(old bool) { aster.PreserveTagOrder = old }(aster.PreserveTagOrder)
+	for _, c := range []struct {
+		preserve bool
+		want     string
+	}{
+		{true, `yaml:"a" json:"a,omitempty" db:"a"`},
+		{false, `db:"a" json:"a,omitempty" yaml:"a"`},
+	} {
+		aster.PreserveTagOrder = c.preserve
+		prog, err := aster.LoadFile("../_out/preserve_tag_order.go", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, _ := prog.Lookup(aster.Typ, aster.Struct, "S")[0].FieldByName("A")
+		a.Tags().AddOptions("json", "omitempty")
+		a.Tags().Set(&aster.Tag{Key: "db", Name: "a"})
+		if got := a.Tags().String(); got != c.want {
+			t.Fatalf("PreserveTagOrder=%v: want %s, got %s", c.preserve, c.want, got)
+		}
+	}
+}
+
+func TestDeadStructFields(t *testing.T) {
+	var src = `package test
+import "encoding/json"
+type Point struct {
+	X, Y  int
+	Label string
+	Tags  []string
+	Meta  struct{ N int }
+	Dead  int
+}
+func New() *Point {
+	p := &Point{X: 1}
+	p.Y++
+	p.Tags[0] = "a"
+	p.Meta.N = 1
+	return p
+}
+func (p Point) Describe() string { return p.Label + string(rune(p.Dead)) }
+type Wire struct{ A int }
+func Decode(b []byte) (w Wire, err error) {
+	err = json.Unmarshal(b, &w)
+	return
+}
+`
+	prog, err := aster.LoadFile("../_out/dead_struct_fields.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sf := range prog.DeadStructFields(prog.Lookup(aster.Typ, 0, "Point")[0]) {
+		names = append(names, sf.Name())
+	}
+	if got := strings.Join(names, ","); got != "Label,Dead" {
+		t.Fatalf("DeadStructFields(Point): want Label,Dead, got %s", got)
+	}
+	if list := prog.DeadStructFields(prog.Lookup(aster.Typ, 0, "Wire")[0]); len(list) != 0 {
+		t.Fatalf("DeadStructFields(Wire): want no fields of the unmarshaled struct, got %d", len(list))
+	}
+}
+
+func TestEmbedders(t *testing.T) {
+	var src = `package test
+import "sync"
+type Base struct{ ID int }
+type User struct {
+	Base
+	Name string
+}
+type Group struct {
+	*Base
+	sync.Mutex
+}
+type Ref struct{ Base Base }
+type Other struct{ Group }
+`
+	prog, err := aster.LoadFile("../_out/embedders.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := prog.Lookup(aster.Typ, aster.Struct, "Base")[0]
+	var names []string
+	for _, fa := range prog.Embedders(base.Object().Type()) {
+		names = append(names, fa.Name())
+	}
+	if strings.Join(names, ",") != "User,Group" {
+		t.Fatalf("Embedders(Base): want User,Group, got %v", names)
+	}
+	mutex := prog.Package("sync").Lookup(aster.Typ, aster.Struct, "Mutex")[0]
+	if list := prog.Embedders(mutex.Object().Type()); len(list) != 1 || list[0].Name() != "Group" {
+		t.Fatalf("Embedders(sync.Mutex): want Group, got %v", list)
+	}
+}
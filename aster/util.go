@@ -5,9 +5,11 @@
 package aster
 
 import (
+	"errors"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -16,6 +18,7 @@ import (
 	"strings"
 	"sync/atomic"
 	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
@@ -44,6 +47,22 @@ func textOrError(text string, err error) string {
 	return "// aster: " + err.Error()
 }
 
+// readSource returns the source code of src, which is a string, []byte or io.Reader,
+// or of the file filename if src is nil, as go/parser does.
+func readSource(filename string, src interface{}) ([]byte, error) {
+	switch s := src.(type) {
+	case nil:
+		return os.ReadFile(filename)
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case io.Reader:
+		return io.ReadAll(s)
+	}
+	return nil, errors.New("aster: invalid source")
+}
+
 var filenameID int32
 
 func autoFilename(f *ast.File) string {
@@ -102,6 +121,12 @@ func isPackageLevel(obj types.Object) bool {
 	return obj.Pkg().Scope().Lookup(obj.Name()) == obj
 }
 
+// upperFirst returns name with the first letter in upper case, such as Size for size.
+func upperFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
 // -- Plundered from go/scanner: ---------------------------------------
 
 func isLetter(ch rune) bool {
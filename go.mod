@@ -1,5 +1,7 @@
 module github.com/henrylee2cn/aster
 
+go 1.23
+
 require (
 	github.com/henrylee2cn/goutil v0.0.0-20181115104016-4a4ae4109d2c
 	github.com/henrylee2cn/structtag v1.0.0